@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -44,46 +45,47 @@ var analyticsCmd = &cobra.Command{
 			utils.UserError(fmt.Sprintf("%s isn't a valid guid", analyticsCmdArgs.clusterID))
 		}
 		if clusterID != "" {
-			cluster, err := api.GetCluster(clusterID)
+			cluster, err := api.GetCluster(cmd.Context(), clusterID)
 
 			if err != nil {
 				utils.UserError(err.Error())
 			}
-			outputClusterAnalytics(api, cluster, false)
+			outputClusterAnalytics(cmd.Context(), api, cluster, false)
 			return
 		}
-		query, err := api.QueryClusters(&client.RequestOptions{Params: client.GetActiveClustersParams()})
+		query, err := api.QueryClusters(cmd.Context(), &client.RequestOptions{Params: client.GetActiveClustersParams()})
 		if err != nil {
 			utils.UserError(err.Error())
 		}
-		for {
-			cluster, err := query.NextCluster()
-			if err != nil {
+		for query.Next() {
+			if err := cmd.Context().Err(); err != nil {
 				utils.UserError(err.Error())
 			}
-			if cluster == nil {
-				break
-			}
-			outputClusterAnalytics(api, cluster, true)
+			outputClusterAnalytics(cmd.Context(), api, query.Value(), true)
+		}
+		if err := query.Err(); err != nil {
+			utils.UserError(err.Error())
 		}
 	},
 }
 
 var customersCache = make(map[string]string)
 
-func outputClusterAnalytics(client *client.Client, cluster *client.Cluster, silenceFailure bool) {
-	analytics, err := client.GetAnalytics(cluster.ID)
+func outputClusterAnalytics(ctx context.Context, client *client.Client, cluster *client.Cluster, silenceFailure bool) {
+	analytics, err := client.GetAnalytics(ctx, cluster.ID)
 	if err != nil {
 		if silenceFailure {
 			return
 		}
 		utils.UserError("Failed to get analytics for cluster %s: %s", cluster.ID, err)
 	}
+	// The response cache may be disabled, so customers are also remembered
+	// for the rest of the run
 	var customerName string
 	if _, ok := customersCache[cluster.CustomerID]; ok {
 		customerName = customersCache[cluster.CustomerID]
 	} else {
-		customer, err := client.GetCustomer(cluster.CustomerID)
+		customer, err := client.GetCustomer(ctx, cluster.CustomerID)
 		if err != nil {
 			if silenceFailure {
 				return
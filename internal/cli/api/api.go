@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weka/gohomecli/internal/cli/app"
+	"github.com/weka/gohomecli/internal/utils"
+	"github.com/weka/gohomecli/pkg/client"
+)
+
+var apiCmdArgs = struct {
+	query      []string
+	data       string
+	inputFile  string
+	prefix     string
+	paginate   bool
+	noEnvelope bool
+	pageSize   int
+}{}
+
+func init() {
+	app.AppCmd.AddCommand(apiCmd)
+	apiCmd.Flags().StringArrayVarP(&apiCmdArgs.query, "query", "q", nil,
+		"add a query parameter, as key=value (repeatable)")
+	apiCmd.Flags().StringVarP(&apiCmdArgs.data, "data", "d", "",
+		"JSON request body")
+	apiCmd.Flags().StringVar(&apiCmdArgs.inputFile, "input", "",
+		"read the JSON request body from a file (\"-\" for stdin)")
+	apiCmd.Flags().StringVar(&apiCmdArgs.prefix, "prefix", "api/v3",
+		"API prefix of the path; ignored for paths starting with \"/\"")
+	apiCmd.Flags().BoolVar(&apiCmdArgs.paginate, "paginate", false,
+		"fetch all pages of a GET request, and output all entities as a single array")
+	apiCmd.Flags().BoolVar(&apiCmdArgs.noEnvelope, "no-envelope", false,
+		"with --paginate, pages are plain arrays rather than {\"data\": [...], \"meta\": {...}} envelopes")
+	apiCmd.Flags().IntVar(&apiCmdArgs.pageSize, "page-size", 0,
+		"with --paginate, number of entities to fetch per page")
+	apiCmd.MarkFlagsMutuallyExclusive("data", "input")
+}
+
+var apiCmd = &cobra.Command{
+	Use:   "api <method> <path>",
+	Short: "Send a raw API request",
+	Long: `Send a raw API request to the current site, and print the JSON response.
+
+The path is relative to the API prefix (api/v3 by default, see --prefix), or to
+the site URL if it starts with "/". For example:
+
+  homecli api GET clusters -q page_size=5
+  homecli api GET /api/<cluster-id>/events/list --paginate --no-envelope`,
+	GroupID: "API",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		method, path := strings.ToUpper(args[0]), args[1]
+		if apiCmdArgs.paginate && method != "GET" {
+			utils.UserError("--paginate can only be used with GET requests")
+		}
+		options := &client.RequestOptions{
+			Prefix:  apiCmdArgs.prefix,
+			Params:  &client.QueryParams{},
+			NoCache: true,
+		}
+		if strings.HasPrefix(path, "/") {
+			options.Prefix = client.RootPrefix
+			path = strings.TrimPrefix(path, "/")
+		}
+		path, rawQuery, _ := strings.Cut(path, "?")
+		if err := addQueryParams(options.Params, rawQuery, apiCmdArgs.query); err != nil {
+			utils.UserError(err.Error())
+		}
+		body, err := readRequestBody()
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		if body != nil {
+			options.Body = body
+		}
+
+		api := client.GetClient()
+		if apiCmdArgs.paginate {
+			options.NoMetadata = apiCmdArgs.noEnvelope
+			options.PageSize = apiCmdArgs.pageSize
+			query, err := client.QueryEntitiesOf[json.RawMessage](cmd.Context(), api, path, options)
+			if err != nil {
+				utils.UserError(err.Error())
+			}
+			entities := []json.RawMessage{}
+			err = query.ForEach(func(entity *json.RawMessage) error {
+				entities = append(entities, *entity)
+				return nil
+			})
+			if err != nil {
+				utils.UserError(err.Error())
+			}
+			output, err := json.MarshalIndent(entities, "", "  ")
+			if err != nil {
+				utils.UserError(err.Error())
+			}
+			utils.UserOutputJSON(output)
+			return
+		}
+		var result json.RawMessage
+		if err := api.SendRequest(cmd.Context(), method, path, &result, options); err != nil {
+			outputErrorBody(err)
+			utils.UserError(err.Error())
+		}
+		outputResponseBody(result)
+	},
+}
+
+// outputResponseBody prints a response body, indenting it if it is JSON
+func outputResponseBody(body []byte) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return
+	}
+	output := &bytes.Buffer{}
+	if err := json.Indent(output, body, "", "  "); err != nil {
+		utils.UserOutput("%s", bytes.TrimRight(body, "\n"))
+		return
+	}
+	utils.UserOutputJSON(output.Bytes())
+}
+
+// outputErrorBody prints the body of an error response, if err is an API
+// error
+func outputErrorBody(err error) {
+	var apiError *client.APIError
+	if !errors.As(err, &apiError) {
+		return
+	}
+	if len(apiError.Errors) == 0 {
+		outputResponseBody(apiError.Body)
+		return
+	}
+	document, err := json.Marshal(map[string]interface{}{"errors": apiError.Errors})
+	if err == nil {
+		outputResponseBody(document)
+	}
+}
+
+// addQueryParams adds the parameters of a raw query string, followed by
+// key=value parameters given on the command line
+func addQueryParams(params *client.QueryParams, rawQuery string, keyValues []string) error {
+	if rawQuery != "" {
+		values, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return fmt.Errorf("invalid query string: %w", err)
+		}
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range values[name] {
+				params.Append(name, value)
+			}
+		}
+	}
+	for _, keyValue := range keyValues {
+		name, value, found := strings.Cut(keyValue, "=")
+		if !found || name == "" {
+			return fmt.Errorf("invalid query parameter \"%s\", expected key=value", keyValue)
+		}
+		params.Append(name, value)
+	}
+	return nil
+}
+
+// readRequestBody returns the JSON request body given by --data or --input,
+// or nil if there is none
+func readRequestBody() (json.RawMessage, error) {
+	var data []byte
+	switch {
+	case apiCmdArgs.data != "":
+		data = []byte(apiCmdArgs.data)
+	case apiCmdArgs.inputFile == "-":
+		var err error
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	case apiCmdArgs.inputFile != "":
+		var err error
+		if data, err = os.ReadFile(apiCmdArgs.inputFile); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	default:
+		return nil, nil
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("request body is not valid JSON")
+	}
+	return json.RawMessage(data), nil
+}
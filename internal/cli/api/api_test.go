@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestAPICommand(t *testing.T) {
+	server := newCLIServer(t)
+	output := runCLI(t, "api", "get", "clusters/"+fakehome.ActiveClusterID+"?fields=name", "-q", "a=1")
+	assertContains(t, output, `"name": "acme-prod"`)
+	requests := server.Requests()
+	if expected := "GET /api/v3/clusters/" + fakehome.ActiveClusterID + "?fields=name&a=1"; requests[len(requests)-1] != expected {
+		t.Errorf("expected %s, got %s", expected, requests[len(requests)-1])
+	}
+
+	output = runCLI(t, "api", "POST", "integrations", "-d", `{"data":{"attributes":{"name":"pager"}}}`)
+	assertContains(t, output, `"name": "pager"`)
+	output = runCLI(t, "api", "DELETE", "/api/v3/integrations/2")
+	if output != "" {
+		t.Errorf("expected no output for an empty response, got %q", output)
+	}
+	assertContains(t, strings.Join(server.Requests(), "\n"), "POST /api/v3/integrations", "DELETE /api/v3/integrations/2")
+
+	output = runCLI(t, "api", "GET", "/api/v3/status?a=1")
+	assertContains(t, output, `"active": true`)
+	requests = server.Requests()
+	if expected := "GET /api/v3/status?a=1"; requests[len(requests)-1] != expected {
+		t.Errorf("expected %s, got %s", expected, requests[len(requests)-1])
+	}
+}
+
+func TestAPICommandPaginate(t *testing.T) {
+	server := newCLIServer(t)
+	output := runCLI(t, "api", "GET", "clusters", "--paginate", "--page-size", "2")
+	var clusters []client.Cluster
+	if err := json.Unmarshal([]byte(output), &clusters); err != nil {
+		t.Fatalf("expected a JSON array, got %s", output)
+	}
+	if len(clusters) != 3 {
+		t.Errorf("expected 3 clusters, got %d", len(clusters))
+	}
+	assertContains(t, strings.Join(server.Requests(), "\n"), "page_size=2&page=2")
+}
+
+func TestAddQueryParams(t *testing.T) {
+	params := &client.QueryParams{}
+	if err := addQueryParams(params, "b=2&a=1&a=3", []string{"c=x=y", "d="}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a=1&a=3&b=2&c=x%3Dy&d="; params.String() != expected {
+		t.Errorf("expected %s, got %s", expected, params.String())
+	}
+	for _, keyValue := range []string{"novalue", "=1"} {
+		if err := addQueryParams(&client.QueryParams{}, "", []string{keyValue}); err == nil {
+			t.Errorf("%s: expected an error", keyValue)
+		}
+	}
+	if err := addQueryParams(&client.QueryParams{}, "a=%zz", nil); err == nil {
+		t.Errorf("expected an invalid query string error")
+	}
+}
+
+func TestOutputResponseBody(t *testing.T) {
+	for body, expected := range map[string]string{
+		"":                "",
+		" \n":             "",
+		`{"a":[1]}`:       "{\n  \"a\": [\n    1\n  ]\n}\n",
+		"plain text\n\n":  "plain text\n",
+		"<html></html>\n": "<html></html>\n",
+	} {
+		output := captureOutput(t, func() { outputResponseBody([]byte(body)) })
+		if output != expected {
+			t.Errorf("%q: expected %q, got %q", body, expected, output)
+		}
+	}
+}
+
+func TestOutputErrorBody(t *testing.T) {
+	output := captureOutput(t, func() {
+		outputErrorBody(&client.APIError{Errors: []client.ErrorObject{{Status: "404", Title: "Not Found"}}})
+	})
+	assertContains(t, output, `"errors"`, `"title": "Not Found"`)
+	output = captureOutput(t, func() { outputErrorBody(&client.APIError{Body: []byte("bad gateway")}) })
+	if output != "bad gateway\n" {
+		t.Errorf("expected the raw error body, got %q", output)
+	}
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/weka/gohomecli/internal/cli/app"
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestMain(m *testing.M) {
+	// The group of the API commands is added by package cli, which imports
+	// this package
+	app.AppCmd.AddGroup(&cobra.Group{ID: "API", Title: "WekaHome API commands"})
+	os.Exit(m.Run())
+}
+
+// newCLIServer starts a fake Weka Home server and points the CLI at it
+func newCLIServer(t *testing.T) *fakehome.Server {
+	t.Helper()
+	server := fakehome.New(nil)
+	t.Cleanup(server.Close)
+	if err := server.ConfigureCLI(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+// runCLI runs the CLI with the given arguments, and returns its standard
+// output. Commands exit on errors, so only successful runs can be tested.
+func runCLI(t *testing.T, args ...string) string {
+	t.Helper()
+	defer resetFlags(app.AppCmd)
+	return captureOutput(t, func() {
+		app.AppCmd.SetArgs(args)
+		if err := app.AppCmd.ExecuteContext(context.Background()); err != nil {
+			t.Fatalf("%s: %s", strings.Join(args, " "), err)
+		}
+	})
+}
+
+// captureOutput calls f, and returns what it writes to the standard output
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+	output, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+	stdout := os.Stdout
+	os.Stdout = output
+	defer func() { os.Stdout = stdout }()
+	f()
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// resetFlags restores the default values of all flags, which are otherwise
+// kept by the commands between runs
+func resetFlags(cmd *cobra.Command) {
+	reset := func(flag *pflag.Flag) {
+		if !flag.Changed {
+			return
+		}
+		if value, ok := flag.Value.(pflag.SliceValue); ok {
+			value.Replace(nil)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, subCmd := range cmd.Commands() {
+		resetFlags(subCmd)
+	}
+}
+
+// chdir changes the working directory for the rest of a test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func assertContains(t *testing.T, output string, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		if !strings.Contains(output, value) {
+			t.Errorf("expected output to contain %q, got:\n%s", value, output)
+		}
+	}
+}
+
+// parseNDJSONEvents parses the output of events --ndjson
+func parseNDJSONEvents(t *testing.T, output string) []*client.Event {
+	t.Helper()
+	var events []*client.Event
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		event := &client.Event{}
+		if err := json.Unmarshal([]byte(line), event); err != nil {
+			t.Fatalf("invalid event %q: %s", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestClusterCommands(t *testing.T) {
+	newCLIServer(t)
+	output := runCLI(t, "cluster", "list")
+	assertContains(t, output, fakehome.ActiveClusterID, "acme-prod", "acme-old", "acme-lab")
+	output = runCLI(t, "cluster", "get", fakehome.ActiveClusterID)
+	assertContains(t, output, "acme-prod", "Acme", "4.2.1", "not muted")
+}
+
+func TestCustomerCommands(t *testing.T) {
+	newCLIServer(t)
+	assertContains(t, runCLI(t, "customer", "list"), fakehome.CustomerID, "Acme")
+	assertContains(t, runCLI(t, "customer", "get", fakehome.CustomerID), "Acme")
+}
+
+func TestStatusCommands(t *testing.T) {
+	newCLIServer(t)
+	assertContains(t, runCLI(t, "server-version"), fakehome.DefaultServerVersion)
+	assertContains(t, runCLI(t, "db-status"), "connected")
+	assertContains(t, runCLI(t, "analytics", "--cluster", fakehome.ActiveClusterID), "total_bytes")
+	assertContains(t, runCLI(t, "usage-report", "--cluster", fakehome.ActiveClusterID), "licensed_capacity_bytes")
+}
+
+func TestEventsCommand(t *testing.T) {
+	server := newCLIServer(t)
+	output := runCLI(t, "events", fakehome.ActiveClusterID, "--limit", "5")
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 6 {
+		t.Errorf("expected a header and 5 events, got:\n%s", output)
+	}
+	requests := server.Requests()
+	assertContains(t, requests[len(requests)-1], "/events/list", "page_size=5")
+
+	events := parseNDJSONEvents(t, runCLI(t, "events", fakehome.ActiveClusterID, "-s", "CRITICAL", "--ndjson"))
+	if len(events) != fakehome.NumActiveEvents/5 {
+		t.Errorf("expected %d events, got %d", fakehome.NumActiveEvents/5, len(events))
+	}
+	for _, event := range events {
+		if event.Severity != "CRITICAL" {
+			t.Errorf("expected only critical events, got %s", event.Severity)
+		}
+	}
+}
+
+func TestDiagsCommands(t *testing.T) {
+	newCLIServer(t)
+	output := runCLI(t, "diags", "list", fakehome.ActiveClusterID)
+	assertContains(t, output, "diags-backend-0.tar.gz", "diags-backend-2.tar.gz", fakehome.DiagsTopicID)
+	dir := t.TempDir()
+	chdir(t, dir)
+	runCLI(t, "diags", "download", fakehome.ActiveClusterID, "diags-backend-1.tar.gz", "--quiet")
+	content, err := os.ReadFile(filepath.Join(dir, "diags-backend-1.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "diagnostics of backend-1\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
@@ -21,6 +22,15 @@ func init() {
 	clusterCmd.AddCommand(clusterAliasCmd)
 	clusterListCmd.Flags().IntVar(&clusterListCmdArgs.Limit, "limit", 500,
 		"show at most this many clusters")
+	clusterListCmd.Flags().IntVar(&clusterListCmdArgs.Prefetch, "prefetch", 0,
+		"fetch this many pages ahead in the background")
+	clusterCmd.AddCommand(clusterMuteCmd)
+	clusterMuteCmd.Flags().StringVar(&clusterMuteCmdArgs.until, "until", "",
+		"mute until this time, e.g. 2024-01-02 18:00, tomorrow 08:00 or +4h")
+	clusterMuteCmd.Flags().DurationVar(&clusterMuteCmdArgs.duration, "for", 0,
+		"mute for this long, e.g. 4h")
+	clusterMuteCmd.MarkFlagsMutuallyExclusive("until", "for")
+	clusterCmd.AddCommand(clusterUnmuteCmd)
 }
 
 var clusterCmd = &cobra.Command{
@@ -42,12 +52,12 @@ var clusterGetCmd = &cobra.Command{
 		if err != nil {
 			utils.UserError(fmt.Sprintf("%s isn't a valid guid", args[0]))
 		}
-		cluster, err := client.GetCluster(clusterID)
+		cluster, err := client.GetCluster(cmd.Context(), clusterID)
 		if err != nil {
 			utils.UserError(err.Error())
 		}
 		var customerName string
-		if customer, err := client.GetClusterCustomer(cluster); err == nil {
+		if customer, err := client.GetClusterCustomer(cmd.Context(), cluster); err == nil {
 			customerName = customer.Name
 		} else {
 			customerName = "N/A"
@@ -57,13 +67,15 @@ var clusterGetCmd = &cobra.Command{
 			table.Append([]string{"ID", cluster.ID})
 			table.Append([]string{"Name", cluster.Name})
 			table.Append([]string{"Version", cluster.Version})
+			table.Append([]string{"Muted", formatMuted(cluster)})
 		})
 	},
 }
 
 var clusterListCmdArgs = struct {
-	active bool
-	Limit  int
+	active   bool
+	Limit    int
+	Prefetch int
 }{}
 
 var clusterListCmd = &cobra.Command{
@@ -77,10 +89,12 @@ var clusterListCmd = &cobra.Command{
 			options.Params = client.GetActiveClustersParams()
 		}
 		options.PageSize = clusterListCmdArgs.Limit
-		query, err := api.QueryClusters(options)
+		options.Prefetch = clusterListCmdArgs.Prefetch
+		query, err := api.QueryClusters(cmd.Context(), options)
 		if err != nil {
 			utils.UserError(err.Error())
 		}
+		defer query.Close()
 		index := 0
 		utils.RenderTableRows(
 			[]string{"ID", "Name", "Version"},
@@ -88,10 +102,7 @@ var clusterListCmd = &cobra.Command{
 				if index >= clusterListCmdArgs.Limit {
 					return nil
 				}
-				cluster, err := query.NextCluster()
-				if err != nil {
-					utils.UserError(err.Error())
-				}
+				cluster := nextOrExit(query)
 				index++
 				if cluster == nil {
 					return nil
@@ -100,3 +111,87 @@ var clusterListCmd = &cobra.Command{
 			})
 	},
 }
+
+var clusterMuteCmdArgs = struct {
+	until    string
+	duration time.Duration
+}{}
+
+var clusterMuteCmd = &cobra.Command{
+	Use:   "mute <cluster-id>...",
+	Short: "Mute cluster alerts",
+	Long: "Mute the alerts of one or more clusters, until a given time (--until), for a given " +
+		"duration (--for), or until they are unmuted",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var until time.Time
+		if clusterMuteCmdArgs.until != "" {
+			var err error
+			if until, err = ParseTime(clusterMuteCmdArgs.until); err != nil {
+				utils.UserError("invalid --until time: %s", err)
+			}
+			if until.Before(time.Now()) {
+				utils.UserError("--until time %s is in the past", clusterMuteCmdArgs.until)
+			}
+		} else if clusterMuteCmdArgs.duration != 0 {
+			if clusterMuteCmdArgs.duration < 0 {
+				utils.UserError("--for duration must be positive")
+			}
+			until = time.Now().Add(clusterMuteCmdArgs.duration).Truncate(time.Second)
+		}
+		api := client.GetClient()
+		updateClusters(args, "mute", func(clusterID string) (*client.Cluster, error) {
+			return api.MuteCluster(cmd.Context(), clusterID, until)
+		})
+	},
+}
+
+var clusterUnmuteCmd = &cobra.Command{
+	Use:   "unmute <cluster-id>...",
+	Short: "Unmute cluster alerts",
+	Long:  "Unmute the alerts of one or more clusters",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		api := client.GetClient()
+		updateClusters(args, "unmute", func(clusterID string) (*client.Cluster, error) {
+			return api.UnmuteCluster(cmd.Context(), clusterID)
+		})
+	},
+}
+
+// updateClusters applies an update to clusters given by ID or alias, reports
+// the outcome for each cluster, and exits with an error if any update failed
+func updateClusters(identifiers []string, action string, update func(clusterID string) (*client.Cluster, error)) {
+	clusterIDs := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		clusterID, err := env.ParseClusterIdentifier(identifier)
+		if err != nil {
+			utils.UserError(fmt.Sprintf("%s isn't a valid guid", identifier))
+		}
+		clusterIDs[i] = clusterID
+	}
+	failed := 0
+	for _, clusterID := range clusterIDs {
+		cluster, err := update(clusterID)
+		if err != nil {
+			utils.UserWarning(err.Error())
+			failed++
+			continue
+		}
+		utils.UserNote("Cluster %s (%s): %s", cluster.Name, cluster.ID, formatMuted(cluster))
+	}
+	if failed > 0 {
+		utils.UserError("failed to %s %d of %d clusters", action, failed, len(clusterIDs))
+	}
+}
+
+func formatMuted(cluster *client.Cluster) string {
+	if !cluster.Muted {
+		return "not muted"
+	}
+	result := "muted"
+	if !cluster.MuteTime.IsZero() {
+		result += " until " + FormatTime(cluster.MuteTime)
+	}
+	return result
+}
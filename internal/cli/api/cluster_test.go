@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestClusterMuteCommands(t *testing.T) {
+	server := newCLIServer(t)
+	runCLI(t, "cluster", "mute", fakehome.ActiveClusterID, "--for", "4h")
+	assertContains(t, runCLI(t, "cluster", "get", fakehome.ActiveClusterID), "muted until")
+	runCLI(t, "cluster", "unmute", fakehome.ActiveClusterID)
+	assertContains(t, runCLI(t, "cluster", "get", fakehome.ActiveClusterID), "not muted")
+
+	runCLI(t, "cluster", "mute", fakehome.ActiveClusterID, fakehome.InactiveClusterID, "--until", "+2h")
+	server.WithFixtures(func(fixtures *fakehome.Fixtures) {
+		for _, cluster := range fixtures.Clusters {
+			if cluster.ID == fakehome.ActiveClusterID || cluster.ID == fakehome.InactiveClusterID {
+				untilIn := time.Until(cluster.MuteTime)
+				if !cluster.Muted || untilIn < time.Hour || untilIn > 2*time.Hour {
+					t.Errorf("expected cluster %s to be muted for 2 hours, got %+v", cluster.ID, cluster)
+				}
+			}
+		}
+	})
+}
+
+func TestFormatMuted(t *testing.T) {
+	for _, test := range []struct {
+		cluster  client.Cluster
+		expected string
+	}{
+		{client.Cluster{}, "not muted"},
+		{client.Cluster{Muted: true}, "muted"},
+	} {
+		if actual := formatMuted(&test.cluster); actual != test.expected {
+			t.Errorf("%+v: expected %q, got %q", test.cluster, test.expected, actual)
+		}
+	}
+}
@@ -29,7 +29,7 @@ var customerGetCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		client := client.GetClient()
-		customer, err := client.GetCustomer(args[0])
+		customer, err := client.GetCustomer(cmd.Context(), args[0])
 		if err != nil {
 			utils.UserError(err.Error())
 		}
@@ -47,17 +47,14 @@ var customerListCmd = &cobra.Command{
 	Long:  "List all customers",
 	Run: func(cmd *cobra.Command, args []string) {
 		api := client.GetClient()
-		query, err := api.QueryCustomers()
+		query, err := api.QueryCustomers(cmd.Context())
 		if err != nil {
 			utils.UserError(err.Error())
 		}
 		utils.RenderTableRows(
 			[]string{"ID", "Name", "Monitored"},
 			func() []string {
-				customer, err := query.NextCustomer()
-				if err != nil {
-					utils.UserError(err.Error())
-				}
+				customer := nextOrExit(query)
 				if customer == nil {
 					return nil
 				}
@@ -12,8 +12,13 @@ import (
 	"github.com/weka/gohomecli/pkg/client"
 )
 
+var diagsCmdArgs = struct {
+	quiet bool
+}{}
+
 var diagsDownloadBacthCmdArgs = struct {
-	topic string
+	topic       string
+	concurrency int
 }{}
 
 var diagsListCmdArgs = struct {
@@ -27,8 +32,12 @@ func init() {
 	diagsCmd.AddCommand(diagsListCmd)
 	diagsCmd.AddCommand(diagsDownloadCmd)
 	diagsCmd.AddCommand(diagsDownloadBacthCmd)
+	diagsCmd.PersistentFlags().BoolVarP(&diagsCmdArgs.quiet, "quiet", "q", false,
+		"do not report download progress")
 	diagsDownloadBacthCmd.Flags().StringVar(&diagsDownloadBacthCmdArgs.topic, "topic", "diags",
 		"topic identifier")
+	diagsDownloadBacthCmd.Flags().IntVar(&diagsDownloadBacthCmdArgs.concurrency, "concurrency",
+		client.DefaultDownloadConcurrency, "download at most this many files at once")
 	diagsListCmd.Flags().StringVar(&diagsListCmdArgs.topicId, "topic-id", "",
 		"filter topic id")
 	diagsListCmd.Flags().StringVar(&diagsListCmdArgs.topic, "topic", "",
@@ -58,7 +67,7 @@ var diagsListCmd = &cobra.Command{
 		options := &client.RequestOptions{}
 		options.PageSize = diagsListCmdArgs.Limit
 		options.Params = client.GetDiagsParams(diagsListCmdArgs.topic, diagsListCmdArgs.topicId)
-		query, err := api.QueryDiags(clusterID, options)
+		query, err := api.QueryDiags(cmd.Context(), clusterID, options)
 		if err != nil {
 			utils.UserError(err.Error())
 		}
@@ -69,10 +78,7 @@ var diagsListCmd = &cobra.Command{
 				return nil
 			}
 			// Get event
-			diag, err := query.NextDiag()
-			if err != nil {
-				utils.UserError(err.Error())
-			}
+			diag := nextOrExit(query)
 			if diag == nil {
 				return nil
 			}
@@ -101,7 +107,9 @@ var diagsDownloadCmd = &cobra.Command{
 			utils.UserError(fmt.Sprintf("%s isn't a valid guid", args[0]))
 		}
 		api := client.GetClient()
-		err = api.DownloadDiags(clusterID, args[1])
+		options, progress := downloadOptions(1)
+		err = api.DownloadDiags(cmd.Context(), clusterID, args[1], options)
+		progress.Stop()
 		if err != nil {
 			utils.UserError(err.Error())
 		}
@@ -122,25 +130,32 @@ var diagsDownloadBacthCmd = &cobra.Command{
 		api := client.GetClient()
 		options := &client.RequestOptions{}
 		options.Params = client.GetDiagsParams(diagsDownloadBacthCmdArgs.topic, args[1])
-		query, err := api.QueryDiags(clusterID, options)
+		query, err := api.QueryDiags(cmd.Context(), clusterID, options)
 		if err != nil {
 			utils.UserError(err.Error())
 		}
 		files := []string{}
-		for {
-			diag, err := query.NextDiag()
-			if err != nil {
-				utils.UserError(err.Error())
-			}
-			if diag == nil {
-				break
-			}
+		err = query.ForEach(func(diag *client.Diag) error {
 			files = append(files, diag.FileName)
+			return nil
+		})
+		if err != nil {
+			utils.UserError(err.Error())
 		}
 		if len(files) > 0 {
-			err := api.DownloadManyDiags(clusterID, files)
+			options, progress := downloadOptions(len(files))
+			options.Concurrency = diagsDownloadBacthCmdArgs.concurrency
+			results, err := api.DownloadManyDiags(cmd.Context(), clusterID, files, options)
+			progress.Stop()
+			outputDownloadResults(results)
 			if err != nil {
-				utils.UserError(err.Error())
+				failed := 0
+				for _, result := range results {
+					if result.Err != nil {
+						failed++
+					}
+				}
+				utils.UserError("%d of %d files failed to download", failed, len(results))
 			}
 		} else {
 			utils.UserOutput("No files found for topic:%s  topic-id: %s",
@@ -148,3 +163,36 @@ var diagsDownloadBacthCmd = &cobra.Command{
 		}
 	},
 }
+
+// downloadOptions returns request options reporting the progress of
+// downloading numFiles files, unless --quiet is set, in which case the
+// returned progress is nil
+func downloadOptions(numFiles int) (*client.RequestOptions, *downloadProgress) {
+	options := &client.RequestOptions{}
+	if diagsCmdArgs.quiet {
+		return options, nil
+	}
+	progress := newDownloadProgress(numFiles)
+	options.Progress = progress
+	return options, progress
+}
+
+func outputDownloadResults(results []client.DownloadResult) {
+	headers := []string{"Filename", "Status", "Size", "Duration", "Error"}
+	index := 0
+	utils.RenderTableRows(headers, func() []string {
+		if index >= len(results) {
+			return nil
+		}
+		result := results[index]
+		index++
+		row := utils.NewTableRow(len(headers))
+		if result.Err != nil {
+			row.Append(result.FileName, utils.Colorize(utils.ColorRed, "FAILED"), "", "", result.Err.Error())
+		} else {
+			row.Append(result.FileName, utils.Colorize(utils.ColorGreen, "OK"),
+				FormatBytes(result.Bytes), FormatDuration(result.Duration), "")
+		}
+		return row.Cells
+	})
+}
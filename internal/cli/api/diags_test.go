@@ -0,0 +1,22 @@
+package api
+
+import (
+	"os"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestDiagsDownloadBatch(t *testing.T) {
+	newCLIServer(t)
+	chdir(t, t.TempDir())
+	output := runCLI(t, "diags", "download-batch", fakehome.ActiveClusterID, fakehome.DiagsTopicID,
+		"--quiet", "--concurrency", "2")
+	for _, fileName := range []string{"diags-backend-0.tar.gz", "diags-backend-1.tar.gz", "diags-backend-2.tar.gz"} {
+		assertContains(t, output, fileName)
+		if _, err := os.Stat(fileName); err != nil {
+			t.Error(err)
+		}
+	}
+	assertContains(t, output, "OK", "25 B")
+}
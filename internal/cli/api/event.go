@@ -1,11 +1,15 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/weka/gohomecli/internal/cli/app"
 	"github.com/weka/gohomecli/internal/env"
 	"github.com/weka/gohomecli/internal/utils"
@@ -14,8 +18,7 @@ import (
 
 func init() {
 	app.AppCmd.AddCommand(eventsCmd)
-	eventsCmd.Flags().BoolVar(&eventsCmdArgs.HideInternal, "hide-internal", false,
-		"do not show internal events")
+	addEventFilterFlags(eventsCmd.Flags())
 	eventsCmd.Flags().BoolVarP(&eventsCmdArgs.ReverseSort, "reverse", "r", false,
 		"sort events from oldest to newest")
 	eventsCmd.Flags().BoolVar(&eventsCmdArgs.ShowEventIDs, "show-event-ids", false,
@@ -24,28 +27,46 @@ func init() {
 		"show event ingest time")
 	eventsCmd.Flags().BoolVar(&eventsCmdArgs.ShowProcessingTime, "show-processing-time", false,
 		"show event processing time")
-	eventsCmd.Flags().BoolVar(&eventsCmdArgs.SortByIngestTime, "by-ingest-time", false,
-		"sort events by ingest time")
 	eventsCmd.Flags().IntVar(&eventsCmdArgs.Limit, "limit", 1000,
 		"show at most this many events")
-	eventsCmd.Flags().StringArrayVarP(&eventsCmdArgs.IncludeTypes, "type", "T", []string{},
-		"show events of these types only")
-	eventsCmd.Flags().StringArrayVarP(&eventsCmdArgs.ExcludeTypes, "exclude-type", "X", nil,
-		"do not show events of these types")
-	eventsCmd.Flags().IntSliceVarP(&eventsCmdArgs.NodeIDs, "node-ids", "n", nil,
-		"show events emitted from these nodes only")
-	eventsCmd.Flags().StringVarP(&eventsCmdArgs.MinSeverity, "min-severity", "s", "",
-		"show events with this severity or higher")
-	eventsCmd.Flags().StringVar(&eventsCmdArgs.StartTime, "start", "",
-		"show events emitted at this time or later")
-	eventsCmd.Flags().StringVar(&eventsCmdArgs.EndTime, "end", "",
-		"show events emitted at this time or later")
 	eventsCmd.Flags().BoolVar(&eventsCmdArgs.Wide, "wide", false,
 		"show more information on events, specifically their params")
 	eventsCmd.Flags().BoolVar(&eventsCmdArgs.Json, "json", false,
 		"Use JSON output format")
-	//eventsCmd.Flags().StringVar(&eventsCmdArgs.Params, "param", "",
-	//	"show events having these parameters")
+	eventsCmd.Flags().BoolVar(&eventsCmdArgs.NDJson, "ndjson", false,
+		"Use newline-delimited JSON output format, one event per line")
+	eventsCmd.Flags().BoolVarP(&eventsCmdArgs.Follow, "follow", "f", false,
+		"keep showing newly ingested events, oldest first, until interrupted")
+	eventsCmd.Flags().DurationVar(&eventsCmdArgs.FollowInterval, "follow-interval", client.DefaultFollowInterval,
+		"with --follow, how often to poll for new events")
+	eventsCmd.MarkFlagsMutuallyExclusive("json", "ndjson")
+	eventsCmd.MarkFlagsMutuallyExclusive("follow", "end")
+	eventsCmd.MarkFlagsMutuallyExclusive("follow", "reverse")
+}
+
+// addEventFilterFlags adds the flags selecting events, which are shared by
+// the events command and its subcommands
+func addEventFilterFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&eventsCmdArgs.HideInternal, "hide-internal", false,
+		"do not show internal events")
+	flags.BoolVar(&eventsCmdArgs.SortByIngestTime, "by-ingest-time", false,
+		"sort events by ingest time")
+	flags.StringArrayVarP(&eventsCmdArgs.IncludeTypes, "type", "T", []string{},
+		"show events of these types only")
+	flags.StringArrayVarP(&eventsCmdArgs.ExcludeTypes, "exclude-type", "X", nil,
+		"do not show events of these types")
+	flags.IntSliceVarP(&eventsCmdArgs.NodeIDs, "node-ids", "n", nil,
+		"show events emitted from these nodes only")
+	flags.StringVarP(&eventsCmdArgs.MinSeverity, "min-severity", "s", "",
+		"show events with this severity or higher")
+	flags.StringVar(&eventsCmdArgs.StartTime, "start", "",
+		"show events emitted at this time or later, e.g. 2024-01-02 15:04, yesterday, -2h or 30m ago")
+	flags.StringVar(&eventsCmdArgs.EndTime, "end", "",
+		"show events emitted before this time, in the same formats as --start")
+	flags.StringArrayVar(&eventsCmdArgs.Params, "param", nil,
+		"show events whose params match name=value, name!=value, name~regex or name!~regex (repeatable)")
+	flags.IntVar(&eventsCmdArgs.Prefetch, "prefetch", 0,
+		"fetch this many pages of events ahead in the background")
 }
 
 var eventsCmdArgs = struct {
@@ -64,7 +85,11 @@ var eventsCmdArgs = struct {
 	EndTime            string
 	Wide               bool
 	Json               bool
-	//Params             string
+	NDJson             bool
+	Prefetch           int
+	Follow             bool
+	FollowInterval     time.Duration
+	Params             []string
 }{}
 
 var eventsCmd = &cobra.Command{
@@ -75,75 +100,34 @@ var eventsCmd = &cobra.Command{
 	GroupID: "API",
 	Args:    cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		startTime, err := ParseTime(eventsCmdArgs.StartTime)
-		if err != nil {
-			utils.UserError(err.Error())
-		}
-		endTime, err := ParseTime(eventsCmdArgs.EndTime)
-		if err != nil {
-			utils.UserError(err.Error())
-			return
-		}
+		clusterID, options := eventQueryOptions(args[0])
+		api := client.GetClient()
+		options.Limit = eventsCmdArgs.Limit
+		options.Wide = eventsCmdArgs.Wide
 		if eventsCmdArgs.ReverseSort {
-			// Need server side support for this. Legacy CLI used to get a single
-			// page of events and then reverse it, but here we want to support
-			// pagination so we have to have the server do the sorting.
-			utils.UserError("--reverse is not supported yet")
-			return
+			options.SortOrder = client.SortAscending
+			options.OnTruncated = func(returned int) {
+				utils.UserWarning("only the newest %d matching events are shown, see --limit", returned)
+			}
 		}
-		clusterID, err := env.ParseClusterIdentifier(args[0])
-		if err != nil {
-			utils.UserError(fmt.Sprintf("%s isn't a valid guid", args[0]))
+		if eventsCmdArgs.Follow {
+			followEvents(cmd, api, clusterID, options)
+			return
 		}
-		api := client.GetClient()
-		query, err := api.QueryEvents(clusterID, &client.EventQueryOptions{
-			WithInternalEvents: !eventsCmdArgs.HideInternal,
-			SortByIngestTime:   eventsCmdArgs.SortByIngestTime,
-			IncludeTypes:       eventsCmdArgs.IncludeTypes,
-			ExcludeTypes:       eventsCmdArgs.ExcludeTypes,
-			NodeIDs:            eventsCmdArgs.NodeIDs,
-			MinSeverity:        eventsCmdArgs.MinSeverity,
-			StartTime:          startTime,
-			EndTime:            endTime,
-			Limit:              eventsCmdArgs.Limit,
-			Wide:               eventsCmdArgs.Wide,
-			//Params:             eventsCmdArgs.Params,
-		})
+		query, err := api.QueryEvents(cmd.Context(), clusterID, options)
 		if err != nil {
 			utils.UserError(err.Error())
 			return
 		}
+		defer query.Close()
 		//query.Options.NoAutoFetchNextPage = false
-		headers := []string{"Time", "Type", "Category"}
-		if eventsCmdArgs.ShowEventIDs {
-			headers = append(headers, "UUID")
-		}
-		if eventsCmdArgs.ShowIngestTime {
-			headers = append(headers, "Cloud Time")
-		}
-		headers = append(headers,
-			"Is Backend", "Node", "Org ID", "Permission", "Processed", "Severity")
-		if eventsCmdArgs.ShowProcessingTime {
-			headers = append(headers, "Processing Time")
-		}
-		if eventsCmdArgs.Wide {
-			headers = append(headers, "Params")
-		}
-		if eventsCmdArgs.Json {
-			for {
-				event, err := query.NextEvent()
-				if err != nil {
-					utils.UserError(err.Error())
-				}
-				if event == nil {
-					break
-				}
-				val, err := json.MarshalIndent(event, "", "    ")
-				if err != nil {
-					utils.UserError(err.Error())
-					return
-				}
-				fmt.Println(string(val))
+		headers := eventHeaders()
+		if eventsCmdArgs.Json || eventsCmdArgs.NDJson {
+			for query.Next() {
+				outputEventJSON(query.Value())
+			}
+			if err := query.Err(); err != nil {
+				utils.UserError(err.Error())
 			}
 			return
 		}
@@ -154,44 +138,153 @@ var eventsCmd = &cobra.Command{
 				return nil
 			}
 			// Get event
-			event, err := query.NextEvent()
-			if err != nil {
-				utils.UserError(err.Error())
-			}
+			event := nextOrExit(query)
 			if event == nil {
 				return nil
 			}
 			numEvents++
-			// Build row
-			row := utils.NewTableRow(len(headers))
-			row.Append(
-				FormatTime(event.Time),
-				FormatEventType(event.EventType),
-				event.Category,
-			)
-			if eventsCmdArgs.ShowEventIDs {
-				row.Append(FormatUUID(event.CloudID))
-			}
-			if eventsCmdArgs.ShowIngestTime {
-				row.Append(FormatTime(event.IngestTime))
-			}
-			row.Append(
-				FormatBoolean(event.IsBackend),
-				FormatNodeID(event.NodeID),
-				strconv.FormatInt(event.OrganizationID, 10),
-				event.Permission,
-				FormatBoolean(event.Processed),
-				FormatEventSeverity(event.Severity),
-			)
-			if eventsCmdArgs.ShowProcessingTime {
-				row.Append(strconv.FormatFloat(event.ComputeProcessingTime(), 'f', 2, 64))
-			}
-			if eventsCmdArgs.Wide {
-				var jsonRawUnescaped json.RawMessage // json raw with unescaped unicode chars
-				jsonRawUnescaped, _ = utils.UnescapeUnicodeCharactersInJSON(event.Params)
-				row.Append(string(jsonRawUnescaped))
-			}
-			return row.Cells
+			return eventRow(headers, event)
 		})
 	},
 }
+
+// eventQueryOptions returns the ID of a cluster given by ID or alias, and
+// options querying its events according to the event filter flags
+func eventQueryOptions(clusterIdentifier string) (string, *client.EventQueryOptions) {
+	startTime, err := ParseTime(eventsCmdArgs.StartTime)
+	if err != nil {
+		utils.UserError("invalid --start time: %s", err)
+	}
+	endTime, err := ParseTime(eventsCmdArgs.EndTime)
+	if err != nil {
+		utils.UserError("invalid --end time: %s", err)
+	}
+	clusterID, err := env.ParseClusterIdentifier(clusterIdentifier)
+	if err != nil {
+		utils.UserError(fmt.Sprintf("%s isn't a valid guid", clusterIdentifier))
+	}
+	options := &client.EventQueryOptions{
+		WithInternalEvents: !eventsCmdArgs.HideInternal,
+		SortByIngestTime:   eventsCmdArgs.SortByIngestTime,
+		SortOrder:          client.SortDescending,
+		IncludeTypes:       eventsCmdArgs.IncludeTypes,
+		ExcludeTypes:       eventsCmdArgs.ExcludeTypes,
+		NodeIDs:            eventsCmdArgs.NodeIDs,
+		MinSeverity:        eventsCmdArgs.MinSeverity,
+		StartTime:          startTime,
+		EndTime:            endTime,
+		Prefetch:           eventsCmdArgs.Prefetch,
+	}
+	for _, text := range eventsCmdArgs.Params {
+		filter, err := client.ParseParamFilter(text)
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		options.Params = append(options.Params, filter)
+	}
+	return clusterID, options
+}
+
+func eventHeaders() []string {
+	headers := []string{"Time", "Type", "Category"}
+	if eventsCmdArgs.ShowEventIDs {
+		headers = append(headers, "UUID")
+	}
+	if eventsCmdArgs.ShowIngestTime {
+		headers = append(headers, "Cloud Time")
+	}
+	headers = append(headers,
+		"Is Backend", "Node", "Org ID", "Permission", "Processed", "Severity")
+	if eventsCmdArgs.ShowProcessingTime {
+		headers = append(headers, "Processing Time")
+	}
+	if eventsCmdArgs.Wide {
+		headers = append(headers, "Params")
+	}
+	return headers
+}
+
+// eventColumnWidths returns the typical width of event table columns
+func eventColumnWidths(headers []string) []int {
+	typicalWidths := map[string]int{
+		"Time": len(time.RFC3339), "Cloud Time": len(time.RFC3339), "Type": 24, "UUID": 36, "Severity": 8,
+	}
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = typicalWidths[header]
+	}
+	return widths
+}
+
+func eventRow(headers []string, event *client.Event) []string {
+	// Build row
+	row := utils.NewTableRow(len(headers))
+	row.Append(
+		FormatTime(event.Time),
+		FormatEventType(event.EventType),
+		event.Category,
+	)
+	if eventsCmdArgs.ShowEventIDs {
+		row.Append(FormatUUID(event.CloudID))
+	}
+	if eventsCmdArgs.ShowIngestTime {
+		row.Append(FormatTime(event.IngestTime))
+	}
+	row.Append(
+		FormatBoolean(event.IsBackend),
+		FormatNodeID(event.NodeID),
+		strconv.FormatInt(event.OrganizationID, 10),
+		event.Permission,
+		FormatBoolean(event.Processed),
+		FormatEventSeverity(event.Severity),
+	)
+	if eventsCmdArgs.ShowProcessingTime {
+		row.Append(strconv.FormatFloat(event.ComputeProcessingTime(), 'f', 2, 64))
+	}
+	if eventsCmdArgs.Wide {
+		var jsonRawUnescaped json.RawMessage // json raw with unescaped unicode chars
+		jsonRawUnescaped, _ = utils.UnescapeUnicodeCharactersInJSON(event.Params)
+		row.Append(string(jsonRawUnescaped))
+	}
+	return row.Cells
+}
+
+func outputEventJSON(event *client.Event) {
+	var val []byte
+	var err error
+	if eventsCmdArgs.NDJson {
+		val, err = json.Marshal(event)
+	} else {
+		val, err = json.MarshalIndent(event, "", "    ")
+	}
+	if err != nil {
+		utils.UserError(err.Error())
+	}
+	fmt.Println(string(val))
+}
+
+// followEvents shows events as they are ingested, until interrupted
+func followEvents(cmd *cobra.Command, api *client.Client, clusterID string, options *client.EventQueryOptions) {
+	var table *utils.TableStream
+	headers := eventHeaders()
+	if !eventsCmdArgs.Json && !eventsCmdArgs.NDJson {
+		table = utils.NewTableStream(headers, eventColumnWidths(headers))
+	}
+	followOptions := &client.FollowOptions{
+		Interval: eventsCmdArgs.FollowInterval,
+		OnError: func(err error, retryIn time.Duration) {
+			utils.UserWarning("failed to fetch new events, retrying in %s: %s", FormatDuration(retryIn), err)
+		},
+	}
+	err := api.FollowEvents(cmd.Context(), clusterID, options, followOptions, func(event *client.Event) error {
+		if table != nil {
+			table.Append(eventRow(headers, event))
+		} else {
+			outputEventJSON(event)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		utils.UserError(err.Error())
+	}
+}
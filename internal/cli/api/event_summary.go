@@ -0,0 +1,303 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/weka/gohomecli/internal/utils"
+	"github.com/weka/gohomecli/pkg/client"
+)
+
+// maxSummaryBuckets bounds the number of time buckets of an event summary
+const maxSummaryBuckets = 1000
+
+// histogramWidth is the width of the longest bar of summary histograms
+const histogramWidth = 40
+
+var eventSeverities = []string{"DEBUG", "INFO", "WARNING", "MINOR", "MAJOR", "CRITICAL"}
+
+func init() {
+	eventsCmd.AddCommand(eventsSummaryCmd)
+	addEventFilterFlags(eventsSummaryCmd.Flags())
+	eventsSummaryCmd.Flags().DurationVar(&eventsSummaryCmdArgs.bucket, "bucket", time.Hour,
+		"length of the time buckets events are counted in")
+	eventsSummaryCmd.Flags().IntVar(&eventsSummaryCmdArgs.limit, "limit", 100000,
+		"summarize at most this many events, the newest ones")
+	eventsSummaryCmd.Flags().BoolVar(&eventsSummaryCmdArgs.json, "json", false,
+		"Use JSON output format")
+}
+
+var eventsSummaryCmdArgs = struct {
+	bucket time.Duration
+	limit  int
+	json   bool
+}{}
+
+var eventsSummaryCmd = &cobra.Command{
+	Use:   "summary <cluster-id>",
+	Short: "Summarize cluster events",
+	Long: `Count the events of a cluster by type, category, severity and node, and over
+time in buckets of --bucket. Events of the last 24 hours are summarized, unless
+--start is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if eventsSummaryCmdArgs.bucket <= 0 {
+			utils.UserError("--bucket must be positive")
+		}
+		clusterID, options := eventQueryOptions(args[0])
+		end := options.EndTime
+		if end.IsZero() {
+			end = time.Now()
+		}
+		if options.StartTime.IsZero() {
+			options.StartTime = end.Add(-24 * time.Hour)
+		}
+		if !options.StartTime.Before(end) {
+			utils.UserError("the start time must be before the end time")
+		}
+		numBuckets := int(end.Sub(options.StartTime.Truncate(eventsSummaryCmdArgs.bucket))/eventsSummaryCmdArgs.bucket) + 1
+		if numBuckets > maxSummaryBuckets {
+			utils.UserError("too many time buckets (%d), use a longer --bucket or a shorter time range", numBuckets)
+		}
+		// Fetching full pages makes summarizing many events faster
+		options.Limit = 1000
+		api := client.GetClient()
+		query, err := api.QueryEvents(cmd.Context(), clusterID, options)
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		defer query.Close()
+		summary := newEventSummary(clusterID, options.StartTime, end, eventsSummaryCmdArgs.bucket)
+		for summary.Total < eventsSummaryCmdArgs.limit && query.Next() {
+			summary.add(query.Value(), options.SortByIngestTime)
+		}
+		if err := query.Err(); err != nil {
+			utils.UserError(err.Error())
+		}
+		if summary.Total == eventsSummaryCmdArgs.limit && query.Next() {
+			summary.Truncated = true
+			utils.UserWarning("only the newest %d events are summarized, see --limit", summary.Total)
+		}
+		summary.sort()
+		if eventsSummaryCmdArgs.json {
+			output, err := json.MarshalIndent(summary, "", "  ")
+			if err != nil {
+				utils.UserError(err.Error())
+			}
+			utils.UserOutputJSON(output)
+			return
+		}
+		outputEventSummary(summary)
+	},
+}
+
+// eventCount is the number of events with a given type, severity, etc.
+type eventCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type eventBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+type eventSummary struct {
+	ClusterID  string        `json:"cluster_id"`
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	Total      int           `json:"total"`
+	Truncated  bool          `json:"truncated"`
+	ByType     []eventCount  `json:"by_type"`
+	ByCategory []eventCount  `json:"by_category"`
+	BySeverity []eventCount  `json:"by_severity"`
+	ByNode     []eventCount  `json:"by_node"`
+	BucketSize string        `json:"bucket_size"`
+	Buckets    []eventBucket `json:"buckets"`
+
+	bucketSize time.Duration
+	counts     map[string]map[string]int
+}
+
+func newEventSummary(clusterID string, start time.Time, end time.Time, bucketSize time.Duration) *eventSummary {
+	summary := &eventSummary{
+		ClusterID:  clusterID,
+		Start:      start.UTC(),
+		End:        end.UTC(),
+		BucketSize: formatBucketSize(bucketSize),
+		bucketSize: bucketSize,
+		counts: map[string]map[string]int{
+			"type": {}, "category": {}, "severity": {}, "node": {},
+		},
+	}
+	for bucketStart := start.Truncate(bucketSize); !bucketStart.After(end); bucketStart = bucketStart.Add(bucketSize) {
+		summary.Buckets = append(summary.Buckets, eventBucket{Start: bucketStart.UTC()})
+	}
+	return summary
+}
+
+func (summary *eventSummary) add(event *client.Event, byIngestTime bool) {
+	summary.Total++
+	summary.counts["type"][event.EventType]++
+	summary.counts["category"][event.Category]++
+	summary.counts["severity"][event.Severity]++
+	summary.counts["node"][FormatNodeID(event.NodeID)]++
+	eventTime := event.Time
+	if byIngestTime {
+		eventTime = event.IngestTime
+	}
+	if len(summary.Buckets) > 0 && !eventTime.Before(summary.Buckets[0].Start) {
+		index := int(eventTime.Sub(summary.Buckets[0].Start) / summary.bucketSize)
+		if index < len(summary.Buckets) {
+			summary.Buckets[index].Count++
+		}
+	}
+}
+
+// sort fills the counts by type, category, etc., most frequent first, except
+// for severities, which are sorted from lowest to highest
+func (summary *eventSummary) sort() {
+	summary.ByType = sortedCounts(summary.counts["type"])
+	summary.ByCategory = sortedCounts(summary.counts["category"])
+	summary.ByNode = sortedCounts(summary.counts["node"])
+	summary.BySeverity = []eventCount{}
+	severities := summary.counts["severity"]
+	for _, severity := range eventSeverities {
+		if count, found := severities[severity]; found {
+			summary.BySeverity = append(summary.BySeverity, eventCount{severity, count})
+		}
+	}
+	// Unknown severities, if any
+	for _, count := range sortedCounts(severities) {
+		if !contains(eventSeverities, count.Key) {
+			summary.BySeverity = append(summary.BySeverity, count)
+		}
+	}
+}
+
+func sortedCounts(counts map[string]int) []eventCount {
+	result := make([]eventCount, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, eventCount{key, count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func outputEventSummary(summary *eventSummary) {
+	utils.UserOutput("%d events of cluster %s from %s to %s", summary.Total, summary.ClusterID,
+		FormatTime(summary.Start), FormatTime(summary.End))
+	sections := []struct {
+		title  string
+		counts []eventCount
+		format func(string) string
+	}{
+		{"Type", summary.ByType, FormatEventType},
+		{"Category", summary.ByCategory, nil},
+		{"Severity", summary.BySeverity, FormatEventSeverity},
+		{"Node", summary.ByNode, nil},
+	}
+	for _, section := range sections {
+		if len(section.counts) == 0 {
+			continue
+		}
+		utils.UserOutput("")
+		maxCount := section.counts[0].Count
+		for _, count := range section.counts {
+			maxCount = max(maxCount, count.Count)
+		}
+		utils.RenderTable([]string{section.title, "Count", "Share", ""}, func(table *tablewriter.Table) {
+			for _, count := range section.counts {
+				key := count.Key
+				if key == "" {
+					key = "(none)"
+				} else if section.format != nil {
+					key = section.format(key)
+				}
+				table.Append([]string{key, strconv.Itoa(count.Count),
+					fmt.Sprintf("%.1f%%", 100*float64(count.Count)/float64(summary.Total)),
+					histogramBar(count.Count, maxCount)})
+			}
+		})
+	}
+
+	utils.UserOutput("")
+	counts := make([]int, len(summary.Buckets))
+	maxCount := 0
+	for i, bucket := range summary.Buckets {
+		counts[i] = bucket.Count
+		maxCount = max(maxCount, bucket.Count)
+	}
+	utils.UserOutput("Events per %s: %s", summary.BucketSize, sparkline(counts))
+	utils.RenderTable([]string{"Time", "Count", ""}, func(table *tablewriter.Table) {
+		for _, bucket := range summary.Buckets {
+			table.Append([]string{FormatTime(bucket.Start), strconv.Itoa(bucket.Count),
+				histogramBar(bucket.Count, maxCount)})
+		}
+	})
+}
+
+// formatBucketSize returns a duration without trailing zero units, e.g. "1h"
+// rather than "1h0m0s"
+func formatBucketSize(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// histogramBar returns a bar whose length is proportional to count
+func histogramBar(count int, maxCount int) string {
+	if maxCount == 0 {
+		return ""
+	}
+	length := (count*histogramWidth + maxCount - 1) / maxCount
+	return utils.Colorize(utils.ColorCyan, strings.Repeat("█", length))
+}
+
+var sparklineLevels = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline returns a single line chart of counts, one character per count
+func sparkline(counts []int) string {
+	maxCount := 0
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+	line := make([]rune, len(counts))
+	for i, count := range counts {
+		switch {
+		case count == 0:
+			line[i] = ' '
+		case maxCount == 1:
+			line[i] = sparklineLevels[len(sparklineLevels)-1]
+		default:
+			line[i] = sparklineLevels[(count-1)*(len(sparklineLevels)-1)/(maxCount-1)]
+		}
+	}
+	return utils.Colorize(utils.ColorCyan, string(line))
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestEventSummary(t *testing.T) {
+	start := time.Date(2024, 1, 2, 10, 30, 0, 0, time.UTC)
+	summary := newEventSummary("cluster", start, start.Add(2*time.Hour), time.Hour)
+	if len(summary.Buckets) != 3 || !summary.Buckets[0].Start.Equal(start.Truncate(time.Hour)) {
+		t.Fatalf("unexpected buckets %+v", summary.Buckets)
+	}
+	events := []client.Event{
+		{EventType: "NodeDisconnected", Severity: "MAJOR", NodeID: "NodeId<1>", Time: start},
+		{EventType: "NodeDisconnected", Severity: "INFO", NodeID: "NodeId<1>", Time: start.Add(time.Hour),
+			IngestTime: start.Add(2 * time.Hour)},
+		{EventType: "DriveActivated", Severity: "UNKNOWN", Category: "Drive", Time: start.Add(time.Hour)},
+		// Outside of the buckets, but still counted
+		{EventType: "DriveActivated", Severity: "INFO", Time: start.Add(-time.Hour)},
+	}
+	for i := range events {
+		summary.add(&events[i], false)
+	}
+	summary.sort()
+	if summary.Total != 4 {
+		t.Errorf("expected 4 events, got %d", summary.Total)
+	}
+	if actual := fmt.Sprint(summary.ByType); actual != "[{DriveActivated 2} {NodeDisconnected 2}]" {
+		t.Errorf("unexpected counts by type %s", actual)
+	}
+	if actual := fmt.Sprint(summary.BySeverity); actual != "[{INFO 2} {MAJOR 1} {UNKNOWN 1}]" {
+		t.Errorf("unexpected counts by severity %s", actual)
+	}
+	if actual := fmt.Sprint(summary.ByCategory); actual != "[{ 3} {Drive 1}]" {
+		t.Errorf("unexpected counts by category %s", actual)
+	}
+	if counts := []int{summary.Buckets[0].Count, summary.Buckets[1].Count, summary.Buckets[2].Count}; fmt.Sprint(counts) != "[1 2 0]" {
+		t.Errorf("unexpected bucket counts %v", counts)
+	}
+	// Events can be counted by ingest time
+	summary.add(&events[1], true)
+	if summary.Buckets[2].Count != 1 {
+		t.Errorf("expected the event to be counted at its ingest time")
+	}
+}
+
+func TestSortedCounts(t *testing.T) {
+	counts := sortedCounts(map[string]int{"b": 1, "a": 1, "c": 3})
+	if actual := fmt.Sprint(counts); actual != "[{c 3} {a 1} {b 1}]" {
+		t.Errorf("expected the most frequent first, then by key, got %s", actual)
+	}
+	if counts := sortedCounts(nil); counts == nil || len(counts) != 0 {
+		t.Errorf("expected an empty list, got %v", counts)
+	}
+}
+
+func TestFormatBucketSize(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		time.Hour:                            "1h",
+		90 * time.Minute:                     "1h30m",
+		15 * time.Minute:                     "15m",
+		30 * time.Second:                     "30s",
+		time.Hour + 5*time.Second:            "1h0m5s",
+		24 * time.Hour:                       "24h",
+		2*time.Minute + 500*time.Millisecond: "2m0.5s",
+	} {
+		if actual := formatBucketSize(d); actual != expected {
+			t.Errorf("%s: expected %s, got %s", d, expected, actual)
+		}
+	}
+}
+
+func TestHistogramBar(t *testing.T) {
+	for _, test := range []struct {
+		count, maxCount, length int
+	}{
+		{0, 0, 0}, {0, 10, 0}, {10, 10, histogramWidth}, {5, 10, histogramWidth / 2}, {1, 1000, 1},
+	} {
+		if bar := []rune(histogramBar(test.count, test.maxCount)); len(bar) != test.length {
+			t.Errorf("%d/%d: expected a bar of length %d, got %d", test.count, test.maxCount, test.length, len(bar))
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	for expected, counts := range map[string][]int{
+		"":          nil,
+		"  ":        {0, 0},
+		"█ █":       {1, 0, 1},
+		"▁▄█ ":      {1, 4, 8, 0},
+		"▁▁▂▃▄▅▆▇█": {1, 2, 3, 4, 5, 6, 7, 8, 9},
+	} {
+		if actual := sparkline(counts); actual != expected {
+			t.Errorf("%v: expected %q, got %q", counts, expected, actual)
+		}
+	}
+}
+
+func TestEventsSummaryCommand(t *testing.T) {
+	newCLIServer(t)
+	output := runCLI(t, "events", "summary", fakehome.ActiveClusterID, "--start", "-3h", "--bucket", "30m", "--json")
+	summary := &eventSummary{}
+	if err := json.Unmarshal([]byte(output), summary); err != nil {
+		t.Fatalf("invalid summary %s: %s", output, err)
+	}
+	if summary.Total != fakehome.NumActiveEvents || summary.Truncated || summary.BucketSize != "30m" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	expected := "[{INFO 24} {WARNING 24} {MINOR 24} {MAJOR 24} {CRITICAL 24}]"
+	if actual := fmt.Sprint(summary.BySeverity); actual != expected {
+		t.Errorf("expected counts by severity %s, got %s", expected, actual)
+	}
+	total := 0
+	for _, bucket := range summary.Buckets {
+		total += bucket.Count
+	}
+	if len(summary.Buckets) < 6 || total != fakehome.NumActiveEvents {
+		t.Errorf("expected all events to be counted in buckets, got %+v", summary.Buckets)
+	}
+
+	output = runCLI(t, "events", "summary", fakehome.ActiveClusterID, "--start", "-3h", "--limit", "10")
+	assertContains(t, output, "10 events of cluster "+fakehome.ActiveClusterID, "Events per 1h: ", "Severity")
+}
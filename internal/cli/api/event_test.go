@@ -0,0 +1,36 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestEventsCommandReverse(t *testing.T) {
+	newCLIServer(t)
+	events := parseNDJSONEvents(t, runCLI(t, "events", fakehome.ActiveClusterID, "--reverse", "--limit", "3", "--ndjson"))
+	if len(events) != 3 || events[0].ID != "1117" || events[2].ID != "1119" {
+		t.Errorf("expected the 3 newest events oldest first, got %+v", events)
+	}
+}
+
+func TestEventsCommandParams(t *testing.T) {
+	newCLIServer(t)
+	output := runCLI(t, "events", fakehome.ActiveClusterID, "--param", "hostname=backend-1", "--limit", "3")
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) != 4 {
+		t.Errorf("expected a header and 3 events, got:\n%s", output)
+	}
+	events := parseNDJSONEvents(t, runCLI(t, "events", fakehome.ActiveClusterID,
+		"--param", "hostname~backend-[12]", "--param", "driveId!=1", "--ndjson"))
+	if len(events) != fakehome.NumActiveEvents*3/8 {
+		t.Errorf("expected %d events, got %d", fakehome.NumActiveEvents*3/8, len(events))
+	}
+	for _, event := range events {
+		if !strings.Contains(string(event.Params), `"hostname":"backend-1"`) &&
+			!strings.Contains(string(event.Params), `"hostname":"backend-2"`) ||
+			strings.Contains(string(event.Params), `"driveId":"1"`) {
+			t.Errorf("unexpected event params %s", event.Params)
+		}
+	}
+}
@@ -1,25 +1,26 @@
 package api
 
 import (
+	"fmt"
 	"regexp"
 	"time"
 
+	"github.com/weka/gohomecli/internal/env"
 	"github.com/weka/gohomecli/internal/utils"
 )
 
+// FormatTime formats a time according to --time-format and --tz
 func FormatTime(t time.Time) string {
-	return utils.Colorize(utils.ColorCyan, t.Format(time.RFC3339))
-}
-
-func ParseTime(text string) (time.Time, error) {
-	if text == "" {
-		return time.Time{}, nil
-	}
-	result, err := time.Parse(time.RFC3339, text)
-	if err != nil {
-		return result, err
+	var text string
+	switch env.TimeFormat {
+	case env.TimeFormatLocal:
+		text = t.In(env.TimeZone).Format(time.RFC3339)
+	case env.TimeFormatRelative:
+		text = formatRelativeTime(t, time.Now())
+	default:
+		text = t.UTC().Format(time.RFC3339)
 	}
-	return result, nil
+	return utils.Colorize(utils.ColorCyan, text)
 }
 
 func FormatBoolean(b bool) string {
@@ -64,3 +65,23 @@ func FormatEventSeverity(severity string) string {
 	}
 	return severity
 }
+
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func FormatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
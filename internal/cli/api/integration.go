@@ -1,12 +1,17 @@
 package api
 
 import (
+	"bufio"
+	"fmt"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
 	"github.com/weka/gohomecli/internal/cli/app"
+	"github.com/weka/gohomecli/internal/env"
 	"github.com/weka/gohomecli/internal/utils"
 	"github.com/weka/gohomecli/pkg/client"
 )
@@ -16,8 +21,38 @@ func init() {
 	integrationCmd.AddCommand(integrationGetCmd)
 	integrationCmd.AddCommand(integrationListCmd)
 	integrationCmd.AddCommand(integrationTestCmd)
+	integrationCmd.AddCommand(integrationCreateCmd)
+	integrationCreateCmd.Flags().StringVarP(&integrationCmdArgs.specFile, "file", "f", "",
+		"YAML or JSON integration spec (\"-\" for stdin)")
+	integrationCreateCmd.MarkFlagRequired("file")
+	integrationCmd.AddCommand(integrationUpdateCmd)
+	integrationUpdateCmd.Flags().StringVarP(&integrationCmdArgs.specFile, "file", "f", "",
+		"YAML or JSON integration spec (\"-\" for stdin)")
+	integrationUpdateCmd.MarkFlagRequired("file")
+	integrationCmd.AddCommand(integrationDeleteCmd)
+	integrationCmd.AddCommand(integrationExportCmd)
+	integrationExportCmd.Flags().StringVar(&integrationCmdArgs.format, "format", "yaml",
+		"output format, yaml or json")
+	integrationExportCmd.Flags().StringVarP(&integrationCmdArgs.outputFile, "output", "o", "",
+		"write to this file instead of stdout")
+	integrationCmd.AddCommand(integrationApplyCmd)
+	integrationApplyCmd.Flags().StringVarP(&integrationCmdArgs.specFile, "file", "f", "",
+		"YAML or JSON integration spec (\"-\" for stdin)")
+	integrationApplyCmd.MarkFlagRequired("file")
+	integrationApplyCmd.Flags().BoolVar(&integrationCmdArgs.dryRun, "dry-run", false,
+		"only show the changes that would be applied")
+	integrationApplyCmd.Flags().BoolVarP(&integrationCmdArgs.yes, "yes", "y", false,
+		"apply the changes without asking for confirmation")
 }
 
+var integrationCmdArgs = struct {
+	specFile   string
+	format     string
+	outputFile string
+	dryRun     bool
+	yes        bool
+}{}
+
 var integrationCmd = &cobra.Command{
 	Use:     "integration",
 	Short:   "Interact with integrations",
@@ -36,7 +71,7 @@ var integrationGetCmd = &cobra.Command{
 		if err != nil {
 			utils.UserError("invalid integration ID: %s", args[0])
 		}
-		integration, err := client.GetIntegration(integrationID)
+		integration, err := client.GetIntegration(cmd.Context(), integrationID)
 		if err != nil {
 			utils.UserError(err.Error())
 		}
@@ -54,17 +89,14 @@ var integrationListCmd = &cobra.Command{
 	Long:  "List all integrations",
 	Run: func(cmd *cobra.Command, args []string) {
 		api := client.GetClient()
-		query, err := api.QueryIntegrations(nil)
+		query, err := api.QueryIntegrations(cmd.Context(), nil)
 		if err != nil {
 			utils.UserError(err.Error())
 		}
 		utils.RenderTableRows(
 			[]string{"ID", "Name", "Type"},
 			func() []string {
-				integration, err := query.NextIntegration()
-				if err != nil {
-					utils.UserError(err.Error())
-				}
+				integration := nextOrExit(query)
 				if integration == nil {
 					return nil
 				}
@@ -84,14 +116,307 @@ var integrationTestCmd = &cobra.Command{
 		if err != nil {
 			utils.UserError("invalid integration ID: %s", args[0])
 		}
-		//integration, err := client.GetIntegration(integrationID)
+		//integration, err := client.GetIntegration(cmd.Context(), integrationID)
 		//if err != nil {
 		//	utils.UserError(err.Error())
 		//}
 		eventCode := args[1]
-		err = client.TestIntegration(integrationID, eventCode)
+		err = client.TestIntegration(cmd.Context(), integrationID, eventCode)
 		if err != nil {
 			utils.UserError("Integration test failed: %s", err)
 		}
 	},
 }
+
+var integrationCreateCmd = &cobra.Command{
+	Use:   "create -f <spec-file>",
+	Short: "Create integrations from a spec",
+	Long: `Create the integrations of a YAML or JSON spec file, in the format of
+"integration export". IDs in the spec are ignored.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		api := client.GetClient()
+		specs, err := readIntegrationSpecs(integrationCmdArgs.specFile)
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		failed := 0
+		for i := range specs {
+			integration, err := api.CreateIntegration(cmd.Context(), &specs[i])
+			if err != nil {
+				utils.UserWarning(err.Error())
+				failed++
+				continue
+			}
+			utils.UserNote("Created integration %d (%s)", integration.ID, integration.Name)
+		}
+		if failed > 0 {
+			utils.UserError("failed to create %d of %d integrations", failed, len(specs))
+		}
+	},
+}
+
+var integrationUpdateCmd = &cobra.Command{
+	Use:   "update <integration-id> -f <spec-file>",
+	Short: "Update an integration from a spec",
+	Long: `Replace the name and configuration of an integration with those of a YAML
+or JSON spec file holding a single integration.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		api := client.GetClient()
+		integrationID, err := strconv.Atoi(args[0])
+		if err != nil {
+			utils.UserError("invalid integration ID: %s", args[0])
+		}
+		specs, err := readIntegrationSpecs(integrationCmdArgs.specFile)
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		if len(specs) != 1 {
+			utils.UserError("expected a single integration in %s, found %d", integrationCmdArgs.specFile, len(specs))
+		}
+		if specs[0].ID != 0 && specs[0].ID != integrationID {
+			utils.UserError("spec is for integration %d, not %d", specs[0].ID, integrationID)
+		}
+		integration, err := api.UpdateIntegration(cmd.Context(), integrationID, &specs[0])
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		utils.UserNote("Updated integration %d (%s)", integration.ID, integration.Name)
+	},
+}
+
+var integrationDeleteCmd = &cobra.Command{
+	Use:   "delete <integration-id>...",
+	Short: "Delete integrations",
+	Long:  "Delete integrations",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		api := client.GetClient()
+		integrationIDs := parseIntegrationIDs(args)
+		failed := 0
+		for _, integrationID := range integrationIDs {
+			if err := api.DeleteIntegration(cmd.Context(), integrationID); err != nil {
+				utils.UserWarning(err.Error())
+				failed++
+				continue
+			}
+			utils.UserNote("Deleted integration %d", integrationID)
+		}
+		if failed > 0 {
+			utils.UserError("failed to delete %d of %d integrations", failed, len(integrationIDs))
+		}
+	},
+}
+
+var integrationExportCmd = &cobra.Command{
+	Use:   "export [<integration-id>...]",
+	Short: "Export integrations as a spec",
+	Long: `Export integrations, all of them unless IDs are given, as a YAML or JSON spec
+that can be edited and applied with "integration apply". A single integration
+is exported as an object, and otherwise a list is exported.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		api := client.GetClient()
+		var integrations []client.Integration
+		if len(args) == 0 {
+			integrations = fetchIntegrations(cmd, api)
+		} else {
+			for _, integrationID := range parseIntegrationIDs(args) {
+				integration, err := api.GetIntegration(cmd.Context(), integrationID)
+				if err != nil {
+					utils.UserError(err.Error())
+				}
+				integrations = append(integrations, *integration)
+			}
+		}
+		var spec interface{} = integrations
+		if len(args) == 1 {
+			spec = integrations[0]
+		} else if len(integrations) == 0 {
+			// Export an empty list rather than null
+			spec = []client.Integration{}
+		}
+		data, err := marshalIntegrationSpecs(spec, integrationCmdArgs.format)
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		if integrationCmdArgs.outputFile != "" {
+			if err := os.WriteFile(integrationCmdArgs.outputFile, data, 0644); err != nil {
+				utils.UserError("failed to write %s: %s", integrationCmdArgs.outputFile, err)
+			}
+			utils.UserNote("Exported %d integrations to %s", len(integrations), integrationCmdArgs.outputFile)
+			return
+		}
+		fmt.Print(string(data))
+	},
+}
+
+var integrationApplyCmd = &cobra.Command{
+	Use:   "apply -f <spec-file>",
+	Short: "Create or update integrations to match a spec",
+	Long: `Create or update integrations to match a YAML or JSON spec file, in the format
+of "integration export". Integrations of the spec are matched to existing ones
+by ID, or by name if they have no ID. Existing integrations missing from the
+spec are left alone.
+
+The changes are shown as a diff, and applied after confirmation.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The answer to the confirmation would be read from the spec's stdin
+		if integrationCmdArgs.specFile == "-" && !integrationCmdArgs.yes && !integrationCmdArgs.dryRun {
+			utils.UserError("--yes or --dry-run is required when the spec is read from stdin")
+		}
+		api := client.GetClient()
+		specs, err := readIntegrationSpecs(integrationCmdArgs.specFile)
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		changes, err := planIntegrationChanges(specs, fetchIntegrations(cmd, api))
+		if err != nil {
+			utils.UserError(err.Error())
+		}
+		numCreated, numUpdated := 0, 0
+		for _, change := range changes {
+			if change.existing == nil {
+				numCreated++
+				utils.UserOutput("Create integration %s:", change.spec.Name)
+			} else if change.diff != nil {
+				numUpdated++
+				utils.UserOutput("Update integration %d (%s):", change.existing.ID, change.existing.Name)
+			} else {
+				continue
+			}
+			for _, line := range change.diff {
+				utils.UserOutput("  %s", colorizeDiffLine(line))
+			}
+		}
+		utils.UserNote("%d to create, %d to update, %d unchanged",
+			numCreated, numUpdated, len(changes)-numCreated-numUpdated)
+		if numCreated+numUpdated == 0 || integrationCmdArgs.dryRun {
+			return
+		}
+		if !integrationCmdArgs.yes && !confirm("Apply these changes?") {
+			utils.UserError("aborted")
+		}
+		failed := 0
+		for _, change := range changes {
+			var integration *client.Integration
+			var err error
+			switch {
+			case change.existing == nil:
+				integration, err = api.CreateIntegration(cmd.Context(), change.spec)
+			case change.diff != nil:
+				integration, err = api.UpdateIntegration(cmd.Context(), change.existing.ID, change.spec)
+			default:
+				continue
+			}
+			if err != nil {
+				utils.UserWarning(err.Error())
+				failed++
+				continue
+			}
+			if change.existing == nil {
+				utils.UserNote("Created integration %d (%s)", integration.ID, integration.Name)
+			} else {
+				utils.UserNote("Updated integration %d (%s)", integration.ID, integration.Name)
+			}
+		}
+		if failed > 0 {
+			utils.UserError("failed to apply %d of %d changes", failed, numCreated+numUpdated)
+		}
+	},
+}
+
+// integrationChange is a change made by "integration apply". existing is nil
+// if the integration is created, and diff is nil if it is unchanged.
+type integrationChange struct {
+	spec     *client.Integration
+	existing *client.Integration
+	diff     []string
+}
+
+// planIntegrationChanges matches specs to existing integrations, and returns
+// the changes needed for the integrations to match the specs
+func planIntegrationChanges(specs []client.Integration, integrations []client.Integration) ([]integrationChange, error) {
+	var changes []integrationChange
+	matched := make(map[int]bool)
+	for i := range specs {
+		spec := &specs[i]
+		var existing *client.Integration
+		for j := range integrations {
+			if spec.ID != 0 && integrations[j].ID == spec.ID ||
+				spec.ID == 0 && integrations[j].Name == spec.Name {
+				if existing != nil {
+					return nil, fmt.Errorf("several integrations are named %s, set the ID of its spec", spec.Name)
+				}
+				existing = &integrations[j]
+			}
+		}
+		if existing == nil && spec.ID != 0 {
+			return nil, fmt.Errorf("integration %d (%s) not found", spec.ID, spec.Name)
+		}
+		change := integrationChange{spec: spec, existing: existing}
+		newLines, err := integrationSpecLines(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integration spec %s: %w", spec.Name, err)
+		}
+		if existing == nil {
+			change.diff = diffLines(nil, newLines)
+		} else {
+			if matched[existing.ID] {
+				return nil, fmt.Errorf("integration %d (%s) appears more than once in the spec", existing.ID, existing.Name)
+			}
+			matched[existing.ID] = true
+			oldLines, err := integrationSpecLines(existing)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integration %d (%s): %w", existing.ID, existing.Name, err)
+			}
+			if strings.Join(oldLines, "\n") != strings.Join(newLines, "\n") {
+				change.diff = diffLines(oldLines, newLines)
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func fetchIntegrations(cmd *cobra.Command, api *client.Client) []client.Integration {
+	query, err := api.QueryIntegrations(cmd.Context(), nil)
+	if err != nil {
+		utils.UserError(err.Error())
+	}
+	var integrations []client.Integration
+	err = query.ForEach(func(integration *client.Integration) error {
+		integrations = append(integrations, *integration)
+		return nil
+	})
+	if err != nil {
+		utils.UserError(err.Error())
+	}
+	return integrations
+}
+
+func parseIntegrationIDs(args []string) []int {
+	integrationIDs := make([]int, len(args))
+	for i, arg := range args {
+		integrationID, err := strconv.Atoi(arg)
+		if err != nil {
+			utils.UserError("invalid integration ID: %s", arg)
+		}
+		integrationIDs[i] = integrationID
+	}
+	return integrationIDs
+}
+
+// confirm asks the user a yes/no question on the terminal, and returns false
+// if the answer is not yes or there is no terminal to ask on
+func confirm(question string) bool {
+	if !env.IsInteractiveTerminal {
+		utils.UserWarning("not asking for confirmation without a terminal, use --yes")
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/weka/gohomecli/internal/utils"
+	"github.com/weka/gohomecli/pkg/client"
+)
+
+// Integration specs are integrations in the JSON format of the API, written
+// in YAML or JSON. A spec file holds a single integration, a list of
+// integrations, or several YAML documents each holding either.
+
+// readIntegrationSpecs reads integration specs from a file, or from stdin if
+// path is "-"
+func readIntegrationSpecs(path string) ([]client.Integration, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read integration spec: %w", err)
+	}
+	specs, err := parseIntegrationSpecs(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid integration spec %s: %w", path, err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("integration spec %s is empty", path)
+	}
+	return specs, nil
+}
+
+func parseIntegrationSpecs(data []byte) ([]client.Integration, error) {
+	var specs []client.Integration
+	// JSON is a subset of YAML, so both are parsed as YAML, and then decoded
+	// as JSON so that the JSON field names of client.Integration apply
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if document == nil {
+			continue
+		}
+		documentJSON, err := json.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		if _, isList := document.([]interface{}); !isList {
+			documentJSON = append(append([]byte("["), documentJSON...), ']')
+		}
+		var documentSpecs []client.Integration
+		jsonDecoder := json.NewDecoder(bytes.NewReader(documentJSON))
+		jsonDecoder.DisallowUnknownFields()
+		if err := jsonDecoder.Decode(&documentSpecs); err != nil {
+			return nil, err
+		}
+		specs = append(specs, documentSpecs...)
+	}
+	for i, spec := range specs {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("integration #%d has no name", i+1)
+		}
+		if spec.Configuration.Type == "" {
+			return nil, fmt.Errorf("integration %s has no configuration.type", spec.Name)
+		}
+	}
+	return specs, nil
+}
+
+// marshalIntegrationSpecs returns an integration or a list of integrations in
+// the given format, "yaml" or "json"
+func marshalIntegrationSpecs(value interface{}, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "json":
+		return append(data, '\n'), nil
+	case "yaml":
+		return jsonToYAML(data)
+	default:
+		return nil, fmt.Errorf("unknown format \"%s\", expected yaml or json", format)
+	}
+}
+
+// jsonToYAML converts JSON to block-style YAML, keeping the order of fields
+func jsonToYAML(data []byte) ([]byte, error) {
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(node)
+	output := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(output)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// resetYAMLStyle drops the flow and quoting styles of YAML parsed from JSON
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// integrationSpecLines returns the YAML lines of the name and configuration of
+// an integration, for comparing and diffing integrations
+func integrationSpecLines(integration *client.Integration) ([]string, error) {
+	spec := *integration
+	spec.ID = 0
+	data, err := marshalIntegrationSpecs(spec, "yaml")
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if !strings.HasPrefix(line, "id: ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// diffLines returns a line diff of two texts, as lines prefixed with "  ",
+// "- " or "+ ", removed lines first. It uses a longest common subsequence,
+// which is fine for texts as short as integration specs.
+func diffLines(oldLines []string, newLines []string) []string {
+	// common[i][j] is the length of the longest common subsequence of
+	// oldLines[i:] and newLines[j:]
+	common := make([][]int, len(oldLines)+1)
+	for i := range common {
+		common[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	var diff []string
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			diff = append(diff, "  "+oldLines[i])
+			i++
+			j++
+		case i < len(oldLines) && (j == len(newLines) || common[i+1][j] >= common[i][j+1]):
+			diff = append(diff, "- "+oldLines[i])
+			i++
+		default:
+			diff = append(diff, "+ "+newLines[j])
+			j++
+		}
+	}
+	return diff
+}
+
+func colorizeDiffLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+"):
+		return utils.Colorize(utils.ColorGreen, line)
+	case strings.HasPrefix(line, "-"):
+		return utils.Colorize(utils.ColorRed, line)
+	default:
+		return line
+	}
+}
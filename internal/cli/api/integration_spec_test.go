@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client"
+)
+
+func TestParseIntegrationSpecs(t *testing.T) {
+	data := `
+name: ops-email
+configuration:
+  type: email
+  rule: {rule_type: severity, param: MAJOR}
+  destinations: [ops@example.com]
+---
+- {"id": 7, "name": "pager", "configuration": {"type": "webhook"}}
+- name: chat
+  configuration:
+    type: slack
+---
+`
+	specs, err := parseIntegrationSpecs([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 3 {
+		t.Fatalf("expected 3 specs, got %d", len(specs))
+	}
+	if specs[0].Name != "ops-email" || string(specs[0].Configuration.Rule.Param) != `"MAJOR"` ||
+		specs[0].Configuration.Destinations[0] != "ops@example.com" {
+		t.Errorf("unexpected spec %+v", specs[0])
+	}
+	if specs[1].ID != 7 || specs[1].Configuration.Type != "webhook" || specs[2].Name != "chat" {
+		t.Errorf("unexpected specs %+v", specs[1:])
+	}
+
+	for data, expected := range map[string]string{
+		"configuration: {type: email}": "integration #1 has no name",
+		"name: x":                      "integration x has no configuration.type",
+		"name: x\nconfiguration: {type: a}\nkey: 1": "unknown field",
+		"name: [": "yaml",
+	} {
+		_, err := parseIntegrationSpecs([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", data, expected, err)
+		}
+	}
+}
+
+func TestMarshalIntegrationSpecs(t *testing.T) {
+	integration := client.Integration{ID: 1, Name: "ops-email"}
+	integration.Configuration.Type = "email"
+	integration.Configuration.Rule = client.IntegrationRule{RuleType: "severity", Param: json.RawMessage(`"MAJOR"`)}
+	integration.Configuration.Destinations = []string{"ops@example.com"}
+	data, err := marshalIntegrationSpecs(integration, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `id: 1
+name: ops-email
+configuration:
+  type: email
+  rule:
+    rule_type: severity
+    param: MAJOR
+  destinations:
+    - ops@example.com
+`
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+	// Exported specs parse back to the same integrations
+	for _, format := range []string{"yaml", "json"} {
+		data, err := marshalIntegrationSpecs([]client.Integration{integration}, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		specs, err := parseIntegrationSpecs(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(specs) != 1 || specs[0].Name != integration.Name || specs[0].ID != integration.ID {
+			t.Errorf("%s: unexpected specs %+v", format, specs)
+		}
+	}
+	if _, err := marshalIntegrationSpecs(integration, "xml"); err == nil {
+		t.Errorf("expected an unknown format error")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	diff := diffLines([]string{"a", "b", "c", "d"}, []string{"a", "x", "c", "d", "e"})
+	expected := []string{"  a", "- b", "+ x", "  c", "  d", "+ e"}
+	if strings.Join(diff, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, diff)
+	}
+	if diff := diffLines(nil, []string{"a"}); len(diff) != 1 || diff[0] != "+ a" {
+		t.Errorf("unexpected diff %q", diff)
+	}
+}
+
+func TestPlanIntegrationChanges(t *testing.T) {
+	existing := []client.Integration{
+		{ID: 1, Name: "ops-email", Configuration: client.IntegrationConfiguration{Type: "email"}},
+		{ID: 2, Name: "pager", Configuration: client.IntegrationConfiguration{Type: "webhook"}},
+	}
+	specs := []client.Integration{
+		{Name: "ops-email", Configuration: client.IntegrationConfiguration{Type: "email"}},
+		{ID: 2, Name: "pager-2", Configuration: client.IntegrationConfiguration{Type: "webhook"}},
+		{Name: "chat", Configuration: client.IntegrationConfiguration{Type: "slack"}},
+	}
+	changes, err := planIntegrationChanges(specs, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	if changes[0].existing.ID != 1 || changes[0].diff != nil {
+		t.Errorf("expected integration 1 to be unchanged, got %+v", changes[0])
+	}
+	if changes[1].existing.ID != 2 || !strings.Contains(strings.Join(changes[1].diff, "\n"), "+ name: pager-2") {
+		t.Errorf("expected integration 2 to be renamed, got %+v", changes[1])
+	}
+	if changes[2].existing != nil || changes[2].diff[0] != "+ name: chat" {
+		t.Errorf("expected integration chat to be created, got %+v", changes[2])
+	}
+
+	for _, test := range []struct {
+		specs    []client.Integration
+		expected string
+	}{
+		{[]client.Integration{{ID: 3, Name: "x"}}, "integration 3 (x) not found"},
+		{[]client.Integration{{ID: 1, Name: "a"}, {Name: "ops-email"}}, "appears more than once"},
+		{[]client.Integration{{Name: "dup"}}, "several integrations are named dup"},
+	} {
+		integrations := append(existing, client.Integration{ID: 4, Name: "dup"}, client.Integration{ID: 5, Name: "dup"})
+		_, err := planIntegrationChanges(test.specs, integrations)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected an error containing %q, got %v", test.expected, err)
+		}
+	}
+}
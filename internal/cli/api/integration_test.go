@@ -0,0 +1,70 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestIntegrationExportAndApply(t *testing.T) {
+	server := newCLIServer(t)
+	exported := runCLI(t, "integration", "export")
+	assertContains(t, exported, "- id: 1\n  name: ops-email\n", "    - ops@example.com\n")
+
+	spec := filepath.Join(t.TempDir(), "spec.yaml")
+	edited := strings.Replace(exported, "ops@example.com", "oncall@example.com", 1) +
+		"- name: pager\n  configuration:\n    type: webhook\n"
+	if err := os.WriteFile(spec, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := runCLI(t, "integration", "apply", "-f", spec, "--dry-run")
+	assertContains(t, output,
+		"Update integration 1 (ops-email):\n",
+		"  -     - ops@example.com\n  +     - oncall@example.com\n",
+		"Create integration pager:\n  + name: pager\n")
+	numRequests := len(server.Requests())
+	for _, request := range server.Requests() {
+		if !strings.HasPrefix(request, "GET ") {
+			t.Errorf("expected a dry run not to change anything, got %s", request)
+		}
+	}
+
+	runCLI(t, "integration", "apply", "-f", spec, "--yes")
+	assertContains(t, strings.Join(server.Requests()[numRequests:], "\n"),
+		"PATCH /api/v3/integrations/1", "POST /api/v3/integrations")
+	// Once applied, the integrations match the spec
+	output = runCLI(t, "integration", "apply", "-f", spec, "--dry-run")
+	if strings.TrimSpace(output) != "" {
+		t.Errorf("expected no changes, got:\n%s", output)
+	}
+	assertContains(t, runCLI(t, "integration", "export", "1", "--format", "json"), `"oncall@example.com"`)
+}
+
+func TestIntegrationCommands(t *testing.T) {
+	server := newCLIServer(t)
+	spec := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(spec, []byte("name: pager\nconfiguration:\n  type: webhook\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runCLI(t, "integration", "create", "-f", spec)
+	if err := os.WriteFile(spec, []byte("name: pager-2\nconfiguration:\n  type: webhook\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runCLI(t, "integration", "update", "2", "-f", spec)
+	assertContains(t, runCLI(t, "integration", "list"), "ops-email", "pager-2")
+	runCLI(t, "integration", "delete", "2")
+	server.WithFixtures(func(fixtures *fakehome.Fixtures) {
+		if len(fixtures.Integrations) != 1 {
+			t.Errorf("expected the created integration to be deleted, got %+v", fixtures.Integrations)
+		}
+	})
+}
+
+func TestIntegrationTestCommand(t *testing.T) {
+	server := newCLIServer(t)
+	runCLI(t, "integration", "test", "1", "TestEvent")
+	assertContains(t, strings.Join(server.Requests(), "\n"), "POST /api/v3/integrations/1/test")
+}
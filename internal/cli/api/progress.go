@@ -0,0 +1,258 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+	"github.com/weka/gohomecli/internal/utils"
+)
+
+const (
+	// progressRefreshInterval is how often progress bars are redrawn
+	progressRefreshInterval = 200 * time.Millisecond
+	// progressLogInterval is how often progress is logged when stderr is not
+	// a terminal
+	progressLogInterval = 10 * time.Second
+	// progressBarWidth is the number of characters in a progress bar
+	progressBarWidth = 30
+	// maxProgressBars is the number of per-file bars shown at once
+	maxProgressBars = 10
+)
+
+type fileProgress struct {
+	fileName   string
+	downloaded int64
+	total      int64
+	// resumedFrom is the number of bytes already downloaded before the
+	// current transfer started, which do not count towards its throughput
+	resumedFrom int64
+	started     time.Time
+	done        bool
+}
+
+func (file *fileProgress) throughput() float64 {
+	elapsed := time.Since(file.started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(file.downloaded-file.resumedFrom) / elapsed
+}
+
+// downloadProgress is a client.ProgressReporter that renders per-file and
+// aggregate progress bars with throughput and ETA on interactive terminals,
+// and prints a progress line every progressLogInterval otherwise
+type downloadProgress struct {
+	interactive bool
+	numFiles    int
+	started     time.Time
+
+	mutex       sync.Mutex
+	files       map[string]*fileProgress
+	active      []*fileProgress
+	completed   []string
+	numDone     int
+	numFailed   int
+	drawnLines  int
+	stopChannel chan struct{}
+	stopped     sync.WaitGroup
+}
+
+// newDownloadProgress starts reporting the progress of downloading numFiles
+// files. Call Stop once all downloads are done.
+func newDownloadProgress(numFiles int) *downloadProgress {
+	progress := &downloadProgress{
+		interactive: env.IsInteractiveStderr,
+		numFiles:    numFiles,
+		started:     time.Now(),
+		files:       make(map[string]*fileProgress),
+		stopChannel: make(chan struct{}),
+	}
+	interval := progressLogInterval
+	if progress.interactive {
+		interval = progressRefreshInterval
+	}
+	progress.stopped.Add(1)
+	go func() {
+		defer progress.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				progress.render()
+			case <-progress.stopChannel:
+				return
+			}
+		}
+	}()
+	return progress
+}
+
+func (progress *downloadProgress) Start(fileName string, offset int64, total int64) {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	file, ok := progress.files[fileName]
+	if !ok {
+		file = &fileProgress{fileName: fileName}
+		progress.files[fileName] = file
+		progress.active = append(progress.active, file)
+	}
+	file.downloaded = offset
+	file.resumedFrom = offset
+	file.total = total
+	file.started = time.Now()
+}
+
+func (progress *downloadProgress) Progress(fileName string, bytes int64) {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	if file, ok := progress.files[fileName]; ok {
+		file.downloaded += bytes
+	}
+}
+
+func (progress *downloadProgress) Done(fileName string, err error) {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	file, ok := progress.files[fileName]
+	if !ok {
+		file = &fileProgress{fileName: fileName, started: time.Now()}
+		progress.files[fileName] = file
+	}
+	file.done = true
+	for i, activeFile := range progress.active {
+		if activeFile == file {
+			progress.active = append(progress.active[:i], progress.active[i+1:]...)
+			break
+		}
+	}
+	var line string
+	if err != nil {
+		progress.numFailed++
+		line = utils.Colorize(utils.ColorError, fmt.Sprintf("Failed to download %s: %s", fileName, err))
+	} else {
+		progress.numDone++
+		line = utils.Colorize(utils.ColorSuccess, fmt.Sprintf("Downloaded %s (%s, %s/s)", fileName,
+			FormatBytes(file.downloaded), FormatBytes(int64(file.throughput()))))
+	}
+	if progress.interactive {
+		progress.completed = append(progress.completed, line)
+	} else {
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// Stop stops reporting progress, and renders the final state. It may be
+// called on a nil downloadProgress.
+func (progress *downloadProgress) Stop() {
+	if progress == nil {
+		return
+	}
+	close(progress.stopChannel)
+	progress.stopped.Wait()
+	if progress.interactive {
+		progress.render()
+	}
+}
+
+func (progress *downloadProgress) render() {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	if !progress.interactive {
+		fmt.Fprintln(os.Stderr, progress.summaryLine())
+		return
+	}
+	builder := &strings.Builder{}
+	if progress.drawnLines > 0 {
+		// Move back to the first progress line, and clear everything below
+		fmt.Fprintf(builder, "\033[%dA", progress.drawnLines)
+	}
+	builder.WriteString("\r\033[J")
+	for _, line := range progress.completed {
+		builder.WriteString(line + "\n")
+	}
+	progress.completed = nil
+	progress.drawnLines = 0
+	nameWidth := 0
+	for i, file := range progress.active {
+		if i < maxProgressBars && len(file.fileName) > nameWidth {
+			nameWidth = len(file.fileName)
+		}
+	}
+	for i, file := range progress.active {
+		if i == maxProgressBars {
+			fmt.Fprintf(builder, "  ... and %d more\n", len(progress.active)-maxProgressBars)
+			progress.drawnLines++
+			break
+		}
+		rate := file.throughput()
+		fmt.Fprintf(builder, "  %-*s  %s\n", nameWidth, file.fileName,
+			formatProgress(file.downloaded, file.total, rate))
+		progress.drawnLines++
+	}
+	builder.WriteString(progress.summaryLine() + "\n")
+	progress.drawnLines++
+	fmt.Fprint(os.Stderr, builder.String())
+}
+
+// summaryLine describes the aggregate progress of all files
+func (progress *downloadProgress) summaryLine() string {
+	var downloaded, transferred, knownTotal int64
+	numKnownTotal := 0
+	for _, file := range progress.files {
+		downloaded += file.downloaded
+		transferred += file.downloaded - file.resumedFrom
+		if file.total >= 0 {
+			knownTotal += file.total
+			numKnownTotal++
+		} else if file.done {
+			knownTotal += file.downloaded
+			numKnownTotal++
+		}
+	}
+	// Estimate the size of files not started yet, or of unknown size, from
+	// the average size of the others
+	estimatedTotal := int64(-1)
+	if numKnownTotal > 0 {
+		estimatedTotal = knownTotal * int64(progress.numFiles) / int64(numKnownTotal)
+	}
+	rate := 0.0
+	if elapsed := time.Since(progress.started).Seconds(); elapsed > 0 {
+		rate = float64(transferred) / elapsed
+	}
+	files := fmt.Sprintf("%d/%d files", progress.numDone, progress.numFiles)
+	if progress.numFailed > 0 {
+		files += fmt.Sprintf(", %d failed", progress.numFailed)
+	}
+	return fmt.Sprintf("Total (%s)  %s", files, formatProgress(downloaded, estimatedTotal, rate))
+}
+
+// formatProgress renders a progress bar with throughput and ETA, or only the
+// downloaded size and throughput if total is unknown
+func formatProgress(downloaded int64, total int64, rate float64) string {
+	throughput := FormatBytes(int64(rate)) + "/s"
+	if total <= 0 {
+		return fmt.Sprintf("%s  %s", FormatBytes(downloaded), throughput)
+	}
+	fraction := float64(downloaded) / float64(total)
+	if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	eta := "--"
+	if rate > 0 && downloaded < total {
+		eta = time.Duration(float64(total-downloaded) / rate * float64(time.Second)).Round(time.Second).String()
+	} else if downloaded >= total {
+		eta = "0s"
+	}
+	return fmt.Sprintf("[%s] %3.0f%%  %s / %s  %s  ETA %s", bar, fraction*100,
+		FormatBytes(downloaded), FormatBytes(total), throughput, eta)
+}
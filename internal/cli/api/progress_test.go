@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatProgress(t *testing.T) {
+	for _, test := range []struct {
+		downloaded int64
+		total      int64
+		rate       float64
+		expected   string
+	}{
+		{512, -1, 100, "512 B  100 B/s"},
+		{0, 2048, 0, "[>                             ]   0%  0 B / 2.0 KiB  0 B/s  ETA --"},
+		{1024, 2048, 512, "[===============>              ]  50%  1.0 KiB / 2.0 KiB  512 B/s  ETA 2s"},
+		{2048, 2048, 512, "[==============================] 100%  2.0 KiB / 2.0 KiB  512 B/s  ETA 0s"},
+	} {
+		if actual := formatProgress(test.downloaded, test.total, test.rate); actual != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestDownloadProgressSummary(t *testing.T) {
+	progress := &downloadProgress{
+		numFiles: 4,
+		started:  time.Now().Add(-time.Second),
+		files:    make(map[string]*fileProgress),
+	}
+	progress.Start("a", 0, 1000)
+	progress.Progress("a", 1000)
+	progress.Done("a", nil)
+	// Resumed bytes do not count towards the throughput
+	progress.Start("b", 500, 1000)
+	progress.Progress("b", 100)
+	progress.Done("c", errors.New("not found"))
+	summary := progress.summaryLine()
+	// Files of unknown size are estimated from the average of the others
+	if !strings.HasPrefix(summary, "Total (1/4 files, 1 failed)  ") ||
+		!strings.Contains(summary, "1.6 KiB / 2.6 KiB") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+	if len(progress.active) != 1 || progress.active[0].fileName != "b" {
+		t.Errorf("expected only b to be active, got %v", progress.active)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for bytes, expected := range map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1024:          "1.0 KiB",
+		1536:          "1.5 KiB",
+		5 << 20:       "5.0 MiB",
+		3 << 30:       "3.0 GiB",
+		1<<40 + 1<<39: "1.5 TiB",
+	} {
+		if actual := FormatBytes(bytes); actual != expected {
+			t.Errorf("%d: expected %s, got %s", bytes, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"github.com/weka/gohomecli/internal/utils"
+	"github.com/weka/gohomecli/pkg/client"
+)
+
+// nextOrExit returns the next entity of a query, or nil if there are no more
+// entities. If fetching the entity fails, it terminates with an error.
+func nextOrExit[T any](query *client.Query[T]) *T {
+	if query.Next() {
+		return query.Value()
+	}
+	if err := query.Err(); err != nil {
+		utils.UserError(err.Error())
+	}
+	return nil
+}
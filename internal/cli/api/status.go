@@ -19,7 +19,7 @@ var serverVersionCmd = &cobra.Command{
 	GroupID: "API",
 	Run: func(cmd *cobra.Command, args []string) {
 		client := client.GetClient()
-		status, err := client.GetServerStatus()
+		status, err := client.GetServerStatus(cmd.Context())
 		if err != nil {
 			utils.UserError(err.Error())
 		}
@@ -34,7 +34,7 @@ var dbStatusCmd = &cobra.Command{
 	GroupID: "API",
 	Run: func(cmd *cobra.Command, args []string) {
 		client := client.GetClient()
-		status, err := client.GetDBStatus()
+		status, err := client.GetDBStatus(cmd.Context())
 		if err != nil {
 			utils.UserError(err.Error())
 		}
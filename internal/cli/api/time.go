@@ -0,0 +1,192 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// Time expressions, as accepted by ParseTime:
+//
+//	2024-01-02T15:04:05Z, 2024-01-02T15:04:05+02:00   RFC 3339
+//	2024-01-02, 2024-01-02 15:04, 2024-01-02T15:04:05  dates, in env.TimeZone
+//	today, yesterday 14:00, tomorrow 08:30:00         calendar words
+//	14:00                                             time of day, today
+//	now, -2h, +1d12h, 30m ago, in 1w                  relative to now
+//	1704207845                                        seconds since the epoch
+//
+// Relative durations are Go durations, with d (24h) and w (7d) units too.
+
+const timeExpressionHelp = "expected RFC 3339, a date such as 2024-01-02 or 2024-01-02 15:04, " +
+	"today, yesterday or tomorrow with an optional time such as 14:00, a duration " +
+	"relative to now such as -2h, +1d, 30m ago or in 1w, or seconds since the epoch"
+
+var (
+	epochPattern    = regexp.MustCompile(`^\d+(\.\d+)?$`)
+	clockPattern    = regexp.MustCompile(`^(\d{1,2}):(\d{2})(?::(\d{2}))?$`)
+	durationPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)(ns|us|µs|ms|s|m|h|d|w)`)
+)
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// ParseTime parses a time expression, see above. It returns the zero time
+// for an empty expression.
+func ParseTime(text string) (time.Time, error) {
+	return parseTimeAt(text, time.Now(), env.TimeZone)
+}
+
+// parseTimeAt parses a time expression relative to now, in a time zone
+func parseTimeAt(text string, now time.Time, location *time.Location) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return time.Time{}, nil
+	}
+	if result, err := time.Parse(time.RFC3339, text); err == nil {
+		return result, nil
+	}
+	if epochPattern.MatchString(text) {
+		seconds, err := strconv.ParseFloat(text, 64)
+		if err == nil {
+			whole, fraction := math.Modf(seconds)
+			return time.Unix(int64(whole), int64(fraction*float64(time.Second))), nil
+		}
+	}
+	lowerText := strings.ToLower(text)
+	if lowerText == "now" {
+		return now, nil
+	}
+	if offset, ok := parseRelativeTime(lowerText); ok {
+		return now.Add(offset), nil
+	}
+	if result, ok := parseCalendarTime(lowerText, now.In(location)); ok {
+		return result, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse \"%s\", %s", text, timeExpressionHelp)
+}
+
+// parseRelativeTime parses an offset from now such as -2h, +1d, 30m ago or
+// in 1w
+func parseRelativeTime(text string) (time.Duration, bool) {
+	var sign time.Duration
+	switch {
+	case strings.HasPrefix(text, "-"):
+		sign, text = -1, text[1:]
+	case strings.HasPrefix(text, "+"):
+		sign, text = 1, text[1:]
+	case strings.HasSuffix(text, " ago"):
+		sign, text = -1, strings.TrimSuffix(text, " ago")
+	case strings.HasPrefix(text, "in "):
+		sign, text = 1, strings.TrimPrefix(text, "in ")
+	default:
+		return 0, false
+	}
+	duration, ok := parseDuration(strings.TrimSpace(text))
+	if !ok {
+		return 0, false
+	}
+	return sign * duration, true
+}
+
+// parseDuration parses a Go duration that may also use d and w units
+func parseDuration(text string) (time.Duration, bool) {
+	if text == "" {
+		return 0, false
+	}
+	var result time.Duration
+	for text != "" {
+		submatches := durationPattern.FindStringSubmatch(text)
+		if submatches == nil {
+			return 0, false
+		}
+		value, err := strconv.ParseFloat(submatches[1], 64)
+		if err != nil {
+			return 0, false
+		}
+		result += time.Duration(value * float64(durationUnits[submatches[2]]))
+		text = text[len(submatches[0]):]
+	}
+	return result, true
+}
+
+// parseCalendarTime parses a day (a date or today, yesterday or tomorrow), a
+// time of day, or both, in the zone of now
+func parseCalendarTime(text string, now time.Time) (time.Time, bool) {
+	dayText, clockText, found := strings.Cut(text, " ")
+	clockText = strings.TrimSpace(clockText)
+	if !found && len(dayText) > len("2006-01-02") && dayText[len("2006-01-02")] == 't' {
+		// 2006-01-02T15:04:05, lowercased
+		dayText, clockText = dayText[:len("2006-01-02")], dayText[len("2006-01-02")+1:]
+	}
+	if clockText == "" && clockPattern.MatchString(dayText) {
+		dayText, clockText = "today", dayText
+	}
+	year, month, day := now.Date()
+	switch dayText {
+	case "today":
+	case "yesterday":
+		year, month, day = now.AddDate(0, 0, -1).Date()
+	case "tomorrow":
+		year, month, day = now.AddDate(0, 0, 1).Date()
+	default:
+		date, err := time.ParseInLocation("2006-01-02", dayText, now.Location())
+		if err != nil {
+			return time.Time{}, false
+		}
+		year, month, day = date.Date()
+	}
+	hour, minute, second := 0, 0, 0
+	if clockText != "" {
+		submatches := clockPattern.FindStringSubmatch(clockText)
+		if submatches == nil {
+			return time.Time{}, false
+		}
+		hour, _ = strconv.Atoi(submatches[1])
+		minute, _ = strconv.Atoi(submatches[2])
+		if submatches[3] != "" {
+			second, _ = strconv.Atoi(submatches[3])
+		}
+		if hour > 23 || minute > 59 || second > 59 {
+			return time.Time{}, false
+		}
+	}
+	return time.Date(year, month, day, hour, minute, second, 0, now.Location()), true
+}
+
+// formatRelativeTime returns how long before or after now a time is, e.g.
+// "5m ago" or "in 2h", in its largest unit
+func formatRelativeTime(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	suffix, prefix := " ago", ""
+	if d < 0 {
+		d, suffix, prefix = -d, "", "in "
+	}
+	var text string
+	switch {
+	case d < time.Second:
+		return "now"
+	case d < time.Minute:
+		text = fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		text = fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		text = fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		text = fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+	return prefix + text + suffix
+}
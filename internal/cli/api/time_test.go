@@ -0,0 +1,94 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+func TestParseTime(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	// It is already January 3rd in the time zone
+	now := time.Date(2024, 1, 2, 23, 30, 0, 0, time.UTC)
+	inZone := func(day int, hour int, minute int, second int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, second, 0, location)
+	}
+	for text, expected := range map[string]time.Time{
+		"":                          {},
+		"2024-01-02T15:04:05Z":      time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		"2024-01-02T15:04:05-05:00": time.Date(2024, 1, 2, 20, 4, 5, 0, time.UTC),
+		"1704207845":                time.Unix(1704207845, 0),
+		"1.5":                       time.Unix(1, int64(500*time.Millisecond)),
+		"now":                       now,
+		" NOW ":                     now,
+		"-2h":                       now.Add(-2 * time.Hour),
+		"+1d12h":                    now.Add(36 * time.Hour),
+		"-1h30m":                    now.Add(-90 * time.Minute),
+		"30m ago":                   now.Add(-30 * time.Minute),
+		"in 1w":                     now.Add(7 * 24 * time.Hour),
+		"+1.5h":                     now.Add(90 * time.Minute),
+		"today":                     inZone(3, 0, 0, 0),
+		"yesterday 14:00":           inZone(2, 14, 0, 0),
+		"Tomorrow 08:30:15":         inZone(4, 8, 30, 15),
+		"9:15":                      inZone(3, 9, 15, 0),
+		"2024-01-05":                inZone(5, 0, 0, 0),
+		"2024-01-05 15:04":          inZone(5, 15, 4, 0),
+		"2024-01-05T15:04:05":       inZone(5, 15, 4, 5),
+	} {
+		actual, err := parseTimeAt(text, now, location)
+		if err != nil {
+			t.Errorf("%q: %s", text, err)
+			continue
+		}
+		if !actual.Equal(expected) {
+			t.Errorf("%q: expected %s, got %s", text, expected, actual)
+		}
+	}
+	for _, text := range []string{"soon", "-2x", "+", "30m", "24:00", "12:60", "2024-13-01", "yesterday noon", "in"} {
+		_, err := parseTimeAt(text, now, location)
+		if err == nil || !strings.HasPrefix(err.Error(), "cannot parse \""+text+"\", expected RFC 3339") {
+			t.Errorf("%q: expected a parse error, got %v", text, err)
+		}
+	}
+}
+
+func TestFormatRelativeTime(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for d, expected := range map[time.Duration]string{
+		0:                          "now",
+		-500 * time.Millisecond:    "now",
+		30 * time.Second:           "30s ago",
+		59*time.Minute + time.Hour: "1h ago",
+		5 * time.Minute:            "5m ago",
+		-2 * time.Hour:             "in 2h",
+		-90 * time.Second:          "in 1m",
+		3*24*time.Hour + time.Hour: "3d ago",
+	} {
+		if actual := formatRelativeTime(now.Add(-d), now); actual != expected {
+			t.Errorf("%s: expected %q, got %q", d, expected, actual)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	defer func(format string, location *time.Location) {
+		env.TimeFormat, env.TimeZone = format, location
+	}(env.TimeFormat, env.TimeZone)
+	env.TimeZone = time.FixedZone("UTC+2", 2*60*60)
+	timestamp := time.Date(2024, 1, 2, 23, 30, 0, 0, time.UTC)
+	for format, expected := range map[string]string{
+		env.TimeFormatUTC:   "2024-01-02T23:30:00Z",
+		env.TimeFormatLocal: "2024-01-03T01:30:00+02:00",
+	} {
+		env.TimeFormat = format
+		if actual := FormatTime(timestamp); actual != expected {
+			t.Errorf("%s: expected %s, got %s", format, expected, actual)
+		}
+	}
+	env.TimeFormat = env.TimeFormatRelative
+	if actual := FormatTime(time.Now().Add(-5*time.Minute - time.Second)); actual != "5m ago" {
+		t.Errorf("expected a relative time, got %s", actual)
+	}
+}
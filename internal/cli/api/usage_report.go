@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -44,32 +45,31 @@ var usageReportCmd = &cobra.Command{
 			utils.UserError(fmt.Sprintf("%s isn't a valid guid", args[0]))
 		}
 		if clusterID != "" {
-			cluster, err := api.GetCluster(clusterID)
+			cluster, err := api.GetCluster(cmd.Context(), clusterID)
 			if err != nil {
 				utils.UserError(err.Error())
 			}
-			outputClusterUsageReport(api, cluster, false)
+			outputClusterUsageReport(cmd.Context(), api, cluster, false)
 			return
 		}
-		query, err := api.QueryClusters(&client.RequestOptions{Params: client.GetActiveClustersParams()})
+		query, err := api.QueryClusters(cmd.Context(), &client.RequestOptions{Params: client.GetActiveClustersParams()})
 		if err != nil {
 			utils.UserError(err.Error())
 		}
-		for {
-			cluster, err := query.NextCluster()
-			if err != nil {
+		for query.Next() {
+			if err := cmd.Context().Err(); err != nil {
 				utils.UserError(err.Error())
 			}
-			if cluster == nil {
-				break
-			}
-			outputClusterUsageReport(api, cluster, true)
+			outputClusterUsageReport(cmd.Context(), api, query.Value(), true)
+		}
+		if err := query.Err(); err != nil {
+			utils.UserError(err.Error())
 		}
 	},
 }
 
-func outputClusterUsageReport(client *client.Client, cluster *client.Cluster, silenceFailure bool) {
-	report, err := client.GetUsageReport(cluster.ID)
+func outputClusterUsageReport(ctx context.Context, client *client.Client, cluster *client.Cluster, silenceFailure bool) {
+	report, err := client.GetUsageReport(ctx, cluster.ID)
 	if err != nil {
 		if silenceFailure {
 			return
@@ -1,7 +1,12 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -13,6 +18,7 @@ var cfgFile string
 var siteName string
 var verboseLogging bool
 var colorMode string
+var timeZone string
 
 func isValidColorMode(mode string) bool {
 	for _, m := range []string{"auto", "always", "never"} {
@@ -38,8 +44,12 @@ var AppCmd = &cobra.Command{
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Commands get a context that is cancelled on SIGINT or SIGTERM, so that
+// in-flight API requests are aborted when the user hits Ctrl-C.
 func Execute() {
-	if err := AppCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := AppCmd.ExecuteContext(ctx); err != nil {
 		utils.UserError(err.Error())
 	}
 }
@@ -55,9 +65,24 @@ func init() {
 		"verbose output")
 	AppCmd.PersistentFlags().StringVar(&colorMode, "color", "auto",
 		"colored output, even when stdout is not a terminal")
+	AppCmd.PersistentFlags().BoolVar(&env.NoCache, "no-cache", false,
+		"do not use or update the cache of API responses")
+	AppCmd.PersistentFlags().StringVar(&env.RecordFile, "record", "",
+		"record all HTTP requests and responses into this cassette file (API keys are redacted)")
+	AppCmd.PersistentFlags().StringVar(&env.ReplayFile, "replay", "",
+		"serve HTTP responses from this cassette file, without network access")
+	AppCmd.PersistentFlags().BoolVar(&env.TraceHTTP, "trace-http", false,
+		"log HTTP request and response headers and bodies, with secrets redacted")
+	AppCmd.PersistentFlags().StringVar(&timeZone, "tz", "",
+		"time zone of times without one, and of times shown in local time, e.g. Europe/Berlin (default $TZ or the system zone)")
+	AppCmd.PersistentFlags().StringVar(&env.TimeFormat, "time-format", env.TimeFormatUTC,
+		"how times are shown: utc, local or relative (e.g. 5m ago)")
 }
 
 func initEnv() {
+	if env.RecordFile != "" && env.ReplayFile != "" {
+		utils.UserError("--record and --replay are mutually exclusive")
+	}
 	switch colorMode {
 	case "always":
 		utils.IsColorOutputSupported = true
@@ -66,11 +91,25 @@ func initEnv() {
 	case "auto":
 		utils.IsColorOutputSupported = env.IsInteractiveTerminal
 	}
+	switch env.TimeFormat {
+	case env.TimeFormatUTC, env.TimeFormatLocal, env.TimeFormatRelative:
+	default:
+		utils.UserError("invalid time format: %s, expected utc, local or relative", env.TimeFormat)
+	}
+	if timeZone != "" {
+		location, err := time.LoadLocation(timeZone)
+		if err != nil {
+			utils.UserError("invalid time zone: %s", timeZone)
+		}
+		env.TimeZone = location
+	}
 }
 
 func initLogging() {
 	if verboseLogging {
 		utils.SetGlobalLoggingLevel(utils.DebugLevel)
+	} else if env.TraceHTTP {
+		utils.SetGlobalLoggingLevel(utils.InfoLevel)
 	}
 }
 
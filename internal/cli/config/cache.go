@@ -0,0 +1,52 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/weka/gohomecli/internal/cli/app"
+	"github.com/weka/gohomecli/internal/env"
+	"github.com/weka/gohomecli/internal/utils"
+	"github.com/weka/gohomecli/pkg/client"
+)
+
+func init() {
+	app.AppCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheClearCmd.Flags().BoolVar(&cacheClearCmdArgs.allSites, "all-sites", false,
+		"clear the cache of all configured sites")
+}
+
+var cacheCmd = &cobra.Command{
+	Use:     "cache",
+	Short:   "API response cache commands",
+	Long:    "API response cache commands",
+	GroupID: "Config",
+}
+
+var cacheClearCmdArgs = struct {
+	allSites bool
+}{}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear cached API responses",
+	Long:  "Clear cached API responses of the current site, or of all sites",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		siteNames := []string{env.SiteName}
+		if cacheClearCmdArgs.allSites {
+			for siteName := range env.CurrentConfig.Sites {
+				if siteName != env.SiteName {
+					siteNames = append(siteNames, siteName)
+				}
+			}
+		}
+		for _, siteName := range siteNames {
+			cache := client.NewResponseCache(client.SiteCacheDir(siteName))
+			if err := cache.Clear(); err != nil {
+				utils.UserError("Failed to clear cache of site \"%s\": %s", siteName, err)
+			}
+			utils.UserNote("Cleared cache of site \"%s\"", siteName)
+		}
+	},
+}
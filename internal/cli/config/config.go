@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
@@ -23,6 +25,19 @@ func init() {
 	configCmd.AddCommand(configDefaultSiteCmd)
 	configSiteCmd.AddCommand(configSiteListCmd)
 	configSiteCmd.AddCommand(configSiteAddCmd)
+	configSiteAddCmd.Flags().StringVar(&configSiteAddCmdArgs.caFile, "ca-file", "",
+		"PEM file of CA certificates to trust in addition to the system ones")
+	configSiteAddCmd.Flags().StringVar(&configSiteAddCmdArgs.clientCert, "client-cert", "",
+		"PEM file of a TLS client certificate")
+	configSiteAddCmd.Flags().StringVar(&configSiteAddCmdArgs.clientKey, "client-key", "",
+		"PEM file of the TLS client certificate's private key")
+	configSiteAddCmd.Flags().BoolVar(&configSiteAddCmdArgs.insecureSkipVerify, "insecure-skip-verify", false,
+		"do not verify the site's TLS certificate (insecure)")
+	configSiteAddCmd.Flags().StringVar(&configSiteAddCmdArgs.proxyURL, "proxy-url", "",
+		"HTTP proxy URL, overriding HTTPS_PROXY and HTTP_PROXY")
+	configSiteAddCmd.Flags().StringVar(&configSiteAddCmdArgs.timeout, "timeout", "",
+		"request timeout, e.g. 30s or 5m (0 disables the timeout)")
+	configSiteAddCmd.MarkFlagsRequiredTogether("client-cert", "client-key")
 	configSiteCmd.AddCommand(configSiteRemoveCmd)
 }
 
@@ -106,6 +121,15 @@ var configSiteListCmd = &cobra.Command{
 	},
 }
 
+var configSiteAddCmdArgs = struct {
+	caFile             string
+	clientCert         string
+	clientKey          string
+	insecureSkipVerify bool
+	proxyURL           string
+	timeout            string
+}{}
+
 var configSiteAddCmd = &cobra.Command{
 	Use:   "add <site> <cloud-url> <api-key>",
 	Short: "Configure a new site",
@@ -113,12 +137,32 @@ var configSiteAddCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(3),
 	Run: func(cmd *cobra.Command, args []string) {
 		siteName, cloudURL, apiKey := args[0], args[1], args[2]
+		if configSiteAddCmdArgs.timeout != "" {
+			if _, err := time.ParseDuration(configSiteAddCmdArgs.timeout); err != nil {
+				utils.UserError("invalid timeout: %s", err)
+			}
+		}
+		if configSiteAddCmdArgs.proxyURL != "" {
+			proxyURL, err := url.Parse(configSiteAddCmdArgs.proxyURL)
+			if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+				utils.UserError("invalid proxy URL: %s", configSiteAddCmdArgs.proxyURL)
+			}
+		}
 		env.UpdateConfig(func(config *env.Config, siteConfig *env.SiteConfig) error {
 			_, exists := config.Sites[siteName]
 			if exists {
 				return fmt.Errorf("site already exists: \"%s\"", siteName)
 			}
-			config.Sites[siteName] = &env.SiteConfig{APIKey: apiKey, CloudURL: cloudURL}
+			config.Sites[siteName] = &env.SiteConfig{
+				APIKey:             apiKey,
+				CloudURL:           cloudURL,
+				CAFile:             configSiteAddCmdArgs.caFile,
+				ClientCert:         configSiteAddCmdArgs.clientCert,
+				ClientKey:          configSiteAddCmdArgs.clientKey,
+				InsecureSkipVerify: configSiteAddCmdArgs.insecureSkipVerify,
+				ProxyURL:           configSiteAddCmdArgs.proxyURL,
+				Timeout:            configSiteAddCmdArgs.timeout,
+			}
 			return nil
 		})
 		utils.UserNote("Added site configuration: \"%s\"", siteName)
@@ -44,6 +44,55 @@ func init() {
 type SiteConfig struct {
 	APIKey   string `toml:"api_key"`
 	CloudURL string `toml:"cloud_url"`
+	// CAFile is a PEM file of CA certificates trusted in addition to the
+	// system ones, e.g. for on-premises sites with a private CA
+	CAFile string `toml:"ca_file,omitempty"`
+	// ClientCert and ClientKey are PEM files of a TLS client certificate and
+	// its private key, for sites requiring mutual TLS
+	ClientCert         string `toml:"client_cert,omitempty"`
+	ClientKey          string `toml:"client_key,omitempty"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify,omitempty"`
+	// ProxyURL overrides the HTTPS_PROXY and HTTP_PROXY environment variables
+	ProxyURL string `toml:"proxy_url,omitempty"`
+	// Timeout of a single request until its response headers are received,
+	// parsable by time.ParseDuration. Zero disables the timeout.
+	Timeout string       `toml:"timeout,omitempty"`
+	Retry   *RetryConfig `toml:"retry,omitempty"`
+	Auth    *AuthConfig  `toml:"auth,omitempty"`
+	Cache   *CacheConfig `toml:"cache,omitempty"`
+}
+
+// CacheConfig controls the on-disk cache of API responses for a site. TTLs
+// are strings parsable by time.ParseDuration, keyed by resource path (e.g.
+// "customers" or "clusters"). MaxAge and MaxSizeMB bound the entries kept on
+// disk.
+type CacheConfig struct {
+	Disabled   bool              `toml:"disabled,omitempty"`
+	DefaultTTL string            `toml:"default_ttl,omitempty"`
+	TTLs       map[string]string `toml:"ttls,omitempty"`
+	MaxAge     string            `toml:"max_age,omitempty"`
+	MaxSizeMB  int               `toml:"max_size_mb,omitempty"`
+}
+
+// AuthConfig selects how API requests to a site are authenticated. Type is one
+// of "token" (the default, using api_key), "env" (reading the token from the
+// environment variable named by EnvVar) or "credential_process" (running
+// Command and using the token it prints). Scheme overrides the Authorization
+// header scheme, e.g. "Bearer" for proxied deployments.
+type AuthConfig struct {
+	Type    string `toml:"type,omitempty"`
+	Scheme  string `toml:"scheme,omitempty"`
+	EnvVar  string `toml:"env_var,omitempty"`
+	Command string `toml:"command,omitempty"`
+}
+
+// RetryConfig overrides the default retry policy of API requests for a site.
+// Durations are strings parsable by time.ParseDuration, e.g. "500ms" or "1m".
+type RetryConfig struct {
+	MaxAttempts        int    `toml:"max_attempts,omitempty"`
+	InitialBackoff     string `toml:"initial_backoff,omitempty"`
+	MaxBackoff         string `toml:"max_backoff,omitempty"`
+	RetryNonIdempotent bool   `toml:"retry_non_idempotent,omitempty"`
 }
 
 // Config holds all global CLI configuration values
@@ -78,6 +127,12 @@ func InitConfig(siteNameFromCommandLine string) {
 		Msg("Site configuration loaded")
 }
 
+// ResetConfig makes the next call to InitConfig read the configuration file
+// again, e.g. after ConfigFilePath was changed
+func ResetConfig() {
+	initialized = false
+}
+
 func createDefaultConfigFileAndExit(createDir bool) {
 	if createDir {
 		os.MkdirAll(ConfigDir, os.ModePerm)
@@ -172,7 +227,8 @@ func getSiteConfig(config *Config, siteNameFromCommandLine string) (*SiteConfig,
 }
 
 func validateSiteConfig(siteConfig *SiteConfig, siteName string) {
-	if siteConfig.APIKey == "" {
+	usesAPIKey := siteConfig.Auth == nil || siteConfig.Auth.Type == "" || siteConfig.Auth.Type == "token"
+	if usesAPIKey && siteConfig.APIKey == "" {
 		utils.UserWarning("config error: \"api_key\" is unset for site %s", siteName)
 	}
 	if siteConfig.CloudURL == "" {
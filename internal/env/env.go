@@ -1,6 +1,9 @@
 package env
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 type VersionInfoAttributes struct {
 	Name      string
@@ -11,8 +14,42 @@ var VersionInfo VersionInfoAttributes
 
 var IsInteractiveTerminal bool
 
+// IsInteractiveStderr is whether stderr, where progress is reported, is a
+// terminal
+var IsInteractiveStderr bool
+
+// NoCache disables the on-disk cache of API responses
+var NoCache bool
+
+// RecordFile and ReplayFile name cassette files to record HTTP interactions
+// into, or to serve HTTP responses from instead of the network
+var (
+	RecordFile string
+	ReplayFile string
+)
+
+// TraceHTTP enables logging of HTTP request and response dumps
+var TraceHTTP bool
+
+// TimeZone is the zone of times given without one on the command line, and
+// of times shown in local time. It defaults to the local zone, which honors TZ.
+var TimeZone = time.Local
+
+// Time formats of TimeFormat
+const (
+	TimeFormatUTC      = "utc"
+	TimeFormatLocal    = "local"
+	TimeFormatRelative = "relative"
+)
+
+// TimeFormat is how times are shown, one of the TimeFormat constants
+var TimeFormat = TimeFormatUTC
+
 func init() {
 	fileInfo, _ := os.Stdout.Stat()
 	IsInteractiveTerminal = (fileInfo.Mode() & os.ModeCharDevice) != 0
+	if fileInfo, err := os.Stderr.Stat(); err == nil {
+		IsInteractiveStderr = (fileInfo.Mode() & os.ModeCharDevice) != 0
+	}
 	//IsColorOutputSupported = IsInteractiveTerminal
 }
@@ -6,8 +6,10 @@ import (
 	"github.com/hokaccha/go-prettyjson"
 	"github.com/olekukonko/tablewriter"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 const (
@@ -140,6 +142,53 @@ func RenderTableRows(headers []string, nextRow func() []string) {
 	table.Render()
 }
 
+// TableStream prints table rows as soon as they are appended, for output
+// that is produced over time, such as followed events. Unlike RenderTable,
+// column widths can't depend on rows that have not been appended yet, so
+// columns are as wide as the widest cell so far.
+type TableStream struct {
+	widths []int
+}
+
+// NewTableStream prints the headers of a table, and returns a stream to
+// append its rows to. Columns are at least as wide as their minimum width, if
+// any, so that typical rows line up with the headers.
+func NewTableStream(headers []string, minWidths []int) *TableStream {
+	stream := &TableStream{widths: make([]int, len(headers))}
+	copy(stream.widths, minWidths)
+	colored := make([]string, len(headers))
+	for i, header := range headers {
+		colored[i] = Colorize(ColorBlue, header)
+	}
+	stream.Append(colored)
+	return stream
+}
+
+// Append prints a row
+func (stream *TableStream) Append(cells []string) {
+	line := &strings.Builder{}
+	for i, cell := range cells {
+		width := visibleWidth(cell)
+		if i < len(stream.widths) && width > stream.widths[i] {
+			stream.widths[i] = width
+		}
+		line.WriteString("  ")
+		line.WriteString(cell)
+		if i < len(stream.widths) && i < len(cells)-1 {
+			line.WriteString(strings.Repeat(" ", stream.widths[i]-width))
+		}
+	}
+	fmt.Println(line.String())
+}
+
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// visibleWidth returns the number of characters of text as shown on a
+// terminal, i.e. ignoring color escape sequences
+func visibleWidth(text string) int {
+	return utf8.RuneCountInString(ansiEscapePattern.ReplaceAllString(text, ""))
+}
+
 type TableRow struct {
 	Cells []string
 	index int
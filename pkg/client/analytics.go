@@ -1,10 +1,13 @@
 package client
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
-func (client *Client) GetAnalytics(clusterID string) ([]byte, error) {
+func (client *Client) GetAnalytics(ctx context.Context, clusterID string) ([]byte, error) {
 	result := &rawResponse{}
-	err := client.Get(fmt.Sprintf("clusters/%s/analytics", clusterID), result, nil)
+	err := client.Get(ctx, fmt.Sprintf("clusters/%s/analytics", clusterID), result, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -2,19 +2,17 @@ package client
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/weka/gohomecli/internal/env"
 	"github.com/weka/gohomecli/internal/utils"
-	"golang.org/x/sync/semaphore"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -31,10 +29,14 @@ type rawResponse struct {
 }
 
 type entityEnvelope struct {
-	ID            interface{}     `json:"id"`
+	ID            interface{}     `json:"id,omitempty"`
 	Type          string          `json:"type"`
 	Attributes    interface{}     `json:"attributes"`
-	Relationships json.RawMessage `json:"relationships"`
+	Relationships json.RawMessage `json:"relationships,omitempty"`
+}
+
+type requestEnvelope struct {
+	Data entityEnvelope `json:"data"`
 }
 
 type responseEnvelope struct {
@@ -56,19 +58,40 @@ type queryResultsEnvelope struct {
 type Client struct {
 	BaseURL       string
 	DefaultPrefix string
-	apiKey        string
+	Authenticator Authenticator
 	HTTPClient    *http.Client
+	RetryPolicy   RetryPolicy
+	// Cache, if set, stores GET responses. See ResponseCache.
+	Cache *ResponseCache
+	// Middlewares wrap the round trip of every request, see Middleware
+	Middlewares []Middleware
+	// Timeout bounds every attempt of a request until its response headers
+	// are received. Response bodies are read without a time limit, so that
+	// large downloads are not interrupted. Zero disables the timeout.
+	Timeout time.Duration
 }
 
-// NewClient creates and returns a new Client instance
+// DefaultTimeout is the Timeout of clients created with NewClient
+const DefaultTimeout = time.Minute
+
+// ErrResponseTimeout is returned, wrapped, by requests whose response headers
+// are not received within the client's Timeout
+var ErrResponseTimeout = errors.New("timed out waiting for response")
+
+// NewClient creates and returns a new Client instance, authenticating with
+// the given API key
 func NewClient(url string, apiKey string) *Client {
 	url = strings.TrimRight(url, "/")
 	return &Client{
 		BaseURL:       url,
 		DefaultPrefix: "api/v3",
-		apiKey:        apiKey,
-		HTTPClient: &http.Client{
-			Timeout: time.Minute,
+		Authenticator: &StaticTokenAuth{Token: apiKey},
+		HTTPClient:    &http.Client{},
+		Timeout:       DefaultTimeout,
+		RetryPolicy:   DefaultRetryPolicy(),
+		Middlewares: []Middleware{
+			UserAgentMiddleware(DefaultUserAgent()),
+			RequestIDMiddleware(),
 		},
 	}
 }
@@ -76,14 +99,80 @@ func NewClient(url string, apiKey string) *Client {
 // GetClient returns a new Client instance, instantiated
 // with values from the CLI configuration file
 func GetClient() *Client {
-	return NewClient(env.CurrentSiteConfig.CloudURL, env.CurrentSiteConfig.APIKey)
+	client := NewClient(env.CurrentSiteConfig.CloudURL, env.CurrentSiteConfig.APIKey)
+	authenticator, err := authenticatorFromConfig(env.CurrentSiteConfig)
+	if err != nil {
+		utils.UserError("config error: invalid auth configuration for site %s: %s", env.SiteName, err)
+	}
+	client.Authenticator = authenticator
+	if env.CurrentSiteConfig.Retry != nil {
+		policy, err := retryPolicyFromConfig(env.CurrentSiteConfig.Retry)
+		if err != nil {
+			utils.UserError("config error: invalid retry configuration for site %s: %s", env.SiteName, err)
+		}
+		client.RetryPolicy = policy
+	}
+	if env.CurrentSiteConfig.Timeout != "" {
+		timeout, err := timeoutFromConfig(env.CurrentSiteConfig)
+		if err != nil {
+			utils.UserError("config error: %s for site %s", err, env.SiteName)
+		}
+		client.Timeout = timeout
+	}
+	if usesCustomTransport(env.CurrentSiteConfig) {
+		siteTransport, err := transportFromConfig(env.CurrentSiteConfig)
+		if err != nil {
+			utils.UserError("config error: invalid TLS or proxy configuration for site %s: %s", env.SiteName, err)
+		}
+		client.HTTPClient.Transport = siteTransport
+	}
+	client.Use(TimingMiddleware(logRequestMetrics))
+	if env.TraceHTTP {
+		client.Use(HTTPTraceMiddleware(DefaultTraceBodyLimit))
+	}
+	transport, err := getCLITransport(client.HTTPClient.Transport)
+	if err != nil {
+		utils.UserError(err.Error())
+	}
+	if transport != nil {
+		client.HTTPClient.Transport = transport
+	}
+	// Cached responses would be missing from recorded cassettes
+	if !env.NoCache && env.RecordFile == "" && env.ReplayFile == "" {
+		cache, err := responseCacheFromConfig(env.SiteName, env.CurrentSiteConfig.Cache)
+		if err != nil {
+			utils.UserError("config error: invalid cache configuration for site %s: %s", env.SiteName, err)
+		}
+		client.Cache = cache
+	}
+	return client
+}
+
+// logRequestMetrics logs the duration of requests sent by the CLI
+func logRequestMetrics(metrics RequestMetrics) {
+	event := logger.Debug().
+		Str("method", metrics.Method).
+		Str("url", metrics.URL).
+		Str("request_id", metrics.RequestID).
+		Dur("duration", metrics.Duration)
+	if metrics.Err != nil {
+		event.Err(metrics.Err).Msg("Request completed")
+	} else {
+		event.Int("status", metrics.StatusCode).Msg("Request completed")
+	}
 }
 
 func (client *Client) getFullURL(url string, options *RequestOptions) string {
 	if options.Prefix == "" {
 		options.Prefix = client.DefaultPrefix
 	}
-	fullURL := fmt.Sprintf("%s/%s/%s", client.BaseURL, options.Prefix, url)
+	fullURL := client.BaseURL
+	if options.Prefix != RootPrefix {
+		fullURL = fmt.Sprintf("%s/%s", fullURL, options.Prefix)
+	}
+	if url != "" || options.Prefix == RootPrefix {
+		fullURL = fmt.Sprintf("%s/%s", fullURL, url)
+	}
 	queryParams := options.Params.String()
 	if queryParams != "" {
 		fullURL = fmt.Sprintf("%s?%s", fullURL, queryParams)
@@ -116,6 +205,18 @@ func (params *QueryParams) GetInt(name string, defaultTo int) int {
 	return defaultTo
 }
 
+// clone returns a copy of the parameters, which can be modified without
+// affecting the original
+func (params *QueryParams) clone() *QueryParams {
+	if params == nil {
+		return &QueryParams{}
+	}
+	return &QueryParams{
+		Names:  append([]string(nil), params.Names...),
+		Values: append([]interface{}(nil), params.Values...),
+	}
+}
+
 // Append adds a new parameter, even if one already exists with the same name
 func (params *QueryParams) Append(name string, value interface{}) *QueryParams {
 	params.Names = append(params.Names, name)
@@ -140,172 +241,354 @@ func (params *QueryParams) String() string {
 	return strings.Join(parts, "&")
 }
 
+// RootPrefix is the RequestOptions.Prefix of URLs relative to the site URL
+// rather than to an API prefix
+const RootPrefix = "/"
+
 type RequestOptions struct {
+	// Prefix is the API prefix URLs are relative to, the client's
+	// DefaultPrefix if empty
 	Prefix              string
 	Params              *QueryParams
 	Body                interface{}
 	NoMetadata          bool
 	NoAutoFetchNextPage bool
 	PageSize            int
+	// NoCache bypasses the client's response cache
+	NoCache bool
+	// Prefetch is the number of pages a paged query fetches ahead in the
+	// background. Zero disables prefetching.
+	Prefetch int
+	// Concurrency is the number of files DownloadMany downloads at once.
+	// Zero means DefaultDownloadConcurrency.
+	Concurrency int
+	// Progress, if set, is notified of the progress of Download and
+	// DownloadMany
+	Progress ProgressReporter
 }
 
-func (client *Client) SendRequest(method string, url string, result interface{}, options *RequestOptions) error {
-	if options == nil {
-		options = &RequestOptions{}
+// encodeBody returns the JSON encoded request body, or nil if there is none
+func (options *RequestOptions) encodeBody() ([]byte, error) {
+	if options.Body == nil {
+		return nil, nil
 	}
-	fullURL := client.getFullURL(url, options)
-	var body io.Reader = nil
-	if options.Body != nil {
-		bodyBytes, err := json.Marshal(options.Body)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal request body: %s", err)
-		}
-		body = bytes.NewReader(bodyBytes)
-	}
-	req, err := http.NewRequest(method, fullURL, body)
+	bodyBytes, err := json.Marshal(options.Body)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
+	return bodyBytes, nil
+}
 
-	req = req.WithContext(context.Background())
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Accept", "application/json; charset=utf-8")
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", client.apiKey))
-
-	logger.Debug().
-		Str("method", req.Method).
-		Str("url", req.URL.String()).
-		Msg("Request")
-
-	res, err := client.HTTPClient.Do(req)
-	if err != nil {
-		return err
-	}
+// do sends a request, retrying it according to the client's RetryPolicy.
+// A new request is built for every attempt, since request bodies can only be
+// read once. The caller is responsible for closing the response body.
+func (client *Client) do(ctx context.Context, method string, fullURL string, bodyBytes []byte,
+	setHeaders func(header http.Header)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		var body io.Reader = nil
+		if bodyBytes != nil {
+			body = bytes.NewReader(bodyBytes)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, fullURL, body)
+		if err != nil {
+			return nil, err
+		}
+		setHeaders(req.Header)
+		if client.Authenticator != nil {
+			if err := client.Authenticator.Authenticate(ctx, req); err != nil {
+				return nil, fmt.Errorf("failed to authenticate request: %w", err)
+			}
+		}
 
-	defer res.Body.Close()
+		logger.Debug().
+			Str("method", req.Method).
+			Str("url", req.URL.String()).
+			Int("attempt", attempt).
+			Msg("Request")
 
-	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
-		logger.Error().
+		res, err := client.roundTripWithTimeout(req)
+		if !client.RetryPolicy.shouldRetry(ctx, method, attempt, res, err) {
+			return res, err
+		}
+		delay := client.RetryPolicy.backoff(attempt, res)
+		event := logger.Warn().
 			Str("method", req.Method).
 			Str("url", req.URL.String()).
-			Int("status", res.StatusCode).
-			Msg("Response")
-		return fmt.Errorf("%s %s returned HTTP %d", req.Method, req.URL, res.StatusCode)
+			Int("attempt", attempt).
+			Dur("delay", delay)
+		if err != nil {
+			event.Err(err).Msg("Request failed, retrying")
+		} else {
+			event.Int("status", res.StatusCode).Msg("Request failed, retrying")
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
 	}
-	logger.Debug().
-		Str("method", req.Method).
-		Str("url", req.URL.String()).
-		Int("status", res.StatusCode).
-		Msg("Response")
+}
 
-	if err = json.NewDecoder(res.Body).Decode(result); err != nil {
-		logger.Error().Err(err).Msg("Unable to parse JSON")
-		return err
+// roundTripWithTimeout sends a request, cancelling it if its response headers
+// are not received within the client's Timeout. The request stays cancellable
+// until its response body is closed.
+func (client *Client) roundTripWithTimeout(req *http.Request) (*http.Response, error) {
+	if client.Timeout <= 0 {
+		return client.roundTrip(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(client.Timeout, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	res, err := client.roundTrip(req.WithContext(ctx))
+	if !timer.Stop() && err == nil {
+		// The timeout expired just as the response arrived
+		res.Body.Close()
+		err = ctx.Err()
+	}
+	if err != nil {
+		cancel()
+		if timedOut.Load() {
+			return nil, fmt.Errorf("%s %s: %w after %s", req.Method, req.URL, ErrResponseTimeout, client.Timeout)
+		}
+		return nil, err
 	}
+	res.Body = &cancelOnCloseBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
 
-	return nil
+// cancelOnCloseBody releases the context of a request when its response body
+// is closed
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnCloseBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
 }
 
-//TODO check if mage sense to use SendRequest
-func (client *Client) Download(url string, fileName string, options *RequestOptions) error {
+// SendRequest sends a request and decodes the JSON response into result. The
+// response is not decoded if result is nil, or if the server responds with
+// 204 No Content or an empty body. The request is aborted when ctx is cancelled or its
+// deadline expires.
+func (client *Client) SendRequest(ctx context.Context, method string, url string, result interface{}, options *RequestOptions) error {
 	if options == nil {
 		options = &RequestOptions{}
 	}
 	fullURL := client.getFullURL(url, options)
-	var body io.Reader = nil
-	if options.Body != nil {
-		bodyBytes, err := json.Marshal(options.Body)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal request body: %s", err)
-		}
-		body = bytes.NewReader(bodyBytes)
-	}
-	req, err := http.NewRequest("GET", fullURL, body)
+	bodyBytes, err := options.encodeBody()
 	if err != nil {
 		return err
 	}
-
-	req = req.WithContext(context.Background())
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", client.apiKey))
-
-	logger.Debug().
-		Str("method", req.Method).
-		Str("url", req.URL.String()).
-		Msg("Request")
-
-	res, err := client.HTTPClient.Do(req)
+	useCache := client.Cache != nil && method == http.MethodGet && !options.NoCache
+	var cached *cacheEntry
+	var identity string
+	if useCache {
+		if identity, err = client.requestIdentity(ctx, fullURL); err != nil {
+			return err
+		}
+		cached = client.Cache.load(identity, fullURL)
+		if cached != nil && cached.isFresh(client.Cache.TTL(url)) {
+			logger.Debug().Str("url", fullURL).Msg("Serving response from cache")
+			return decodeJSON(cached.Body, result)
+		}
+	}
+	res, err := client.do(ctx, method, fullURL, bodyBytes, func(header http.Header) {
+		header.Set("Content-Type", "application/json; charset=utf-8")
+		header.Set("Accept", "application/json; charset=utf-8")
+		if cached != nil {
+			cached.setConditionalHeaders(header)
+		}
+	})
 	if err != nil {
 		return err
 	}
 
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		logger.Debug().Str("url", fullURL).Msg("Cached response revalidated")
+		cached.StoredAt = time.Now()
+		if err := client.Cache.store(cached); err != nil {
+			logger.Debug().Err(err).Msg("Failed to update cache")
+		}
+		return decodeJSON(cached.Body, result)
+	}
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
 		logger.Error().
-			Str("method", req.Method).
-			Str("url", req.URL.String()).
+			Str("method", method).
+			Str("url", res.Request.URL.String()).
 			Int("status", res.StatusCode).
 			Msg("Response")
-		return fmt.Errorf("%s %s returned HTTP %d", req.Method, req.URL, res.StatusCode)
+		return newAPIError(method, res)
 	}
 	logger.Debug().
-		Str("method", req.Method).
-		Str("url", req.URL.String()).
+		Str("method", method).
+		Str("url", res.Request.URL.String()).
 		Int("status", res.StatusCode).
 		Msg("Response")
 
-	var reader io.ReadCloser
-	switch res.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err = gzip.NewReader(res.Body)
-		defer reader.Close()
-	default:
-		reader = res.Body
+	if client.Cache != nil && method != http.MethodGet {
+		// The resource was modified, so cached copies of it and of the
+		// collection it belongs to would be stale
+		client.Cache.removeResource(client.resourceURL(url, options))
 	}
-	destFile, err := os.Create(fileName)
+	if result == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if !useCache {
+		if err = json.NewDecoder(res.Body).Decode(result); err != nil {
+			if err == io.EOF {
+				// Empty body
+				return nil
+			}
+			logger.Error().Err(err).Msg("Unable to parse JSON")
+			return err
+		}
+		return nil
+	}
+	responseBytes, err := io.ReadAll(res.Body)
 	if err != nil {
-		return fmt.Errorf("failed to open destination file: %s", err)
+		return err
+	}
+	if len(bytes.TrimSpace(responseBytes)) == 0 {
+		return nil
+	}
+	if err = decodeJSON(responseBytes, result); err != nil {
+		return err
+	}
+	entry := newCacheEntry(identity, fullURL, res, responseBytes)
+	if !entry.isReusable(client.Cache.TTL(url)) {
+		if cached != nil {
+			client.Cache.remove(identity, fullURL)
+		}
+		return nil
+	}
+	if err := client.Cache.store(entry); err != nil {
+		logger.Debug().Err(err).Msg("Failed to update cache")
+	}
+	if err := client.Cache.Prune(); err != nil {
+		logger.Debug().Err(err).Msg("Failed to prune cache")
 	}
-	defer destFile.Close()
-	utils.UserOutput("Downloading " + fileName)
-	io.Copy(destFile, res.Body)
 	return nil
 }
 
-func (client *Client) DownloadMany(urlTemplate string, fileNames []string, options *RequestOptions) error {
-	sem := semaphore.NewWeighted(16)
-	baseContext := context.Background()
-	wg := sync.WaitGroup{}
-	for _, file := range fileNames {
-		wg.Add(1)
-		_ = sem.Acquire(baseContext, 1)
-		go func(file string) {
-			client.Download(fmt.Sprintf(urlTemplate, file), file, options)
-			wg.Done()
-			sem.Release(1)
-		}(file)
-	}
-	wg.Wait()
+// requestIdentity returns the identity of the credentials requests to fullURL
+// are sent with, which cached responses are keyed by
+func (client *Client) requestIdentity(ctx context.Context, fullURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if client.Authenticator != nil {
+		if err := client.Authenticator.Authenticate(ctx, req); err != nil {
+			return "", fmt.Errorf("failed to authenticate request: %w", err)
+		}
+	}
+	return credentialsIdentity(req.Header), nil
+}
+
+// resourceURL returns the full URL of the top-level resource of a request,
+// e.g. that of "integrations" for "integrations/1/test"
+func (client *Client) resourceURL(url string, options *RequestOptions) string {
+	path := strings.Trim(strings.SplitN(url, "?", 2)[0], "/")
+	resource, _, _ := strings.Cut(path, "/")
+	return client.getFullURL(resource, &RequestOptions{Prefix: options.Prefix})
+}
+
+func decodeJSON(data []byte, result interface{}) error {
+	if err := json.Unmarshal(data, result); err != nil {
+		logger.Error().Err(err).Msg("Unable to parse JSON")
+		return err
+	}
 	return nil
 }
 
 // Get sends a GET request, and does not expect the response to be enveloped
-func (client *Client) Get(url string, result interface{}, options *RequestOptions) error {
-	return client.SendRequest("GET", url, result, options)
+func (client *Client) Get(ctx context.Context, url string, result interface{}, options *RequestOptions) error {
+	return client.SendRequest(ctx, "GET", url, result, options)
 }
 
 // GetAPIEntity is a general implementation for getting a single object from an
 // API resource
-func (client *Client) GetAPIEntity(resource string, id interface{}, result interface{}) error {
+func (client *Client) GetAPIEntity(ctx context.Context, resource string, id interface{}, result interface{}) error {
 	entity := responseEnvelope{
 		Data: entityEnvelope{
 			Attributes: result,
 		},
 	}
-	return client.Get(fmt.Sprintf("%s/%v", resource, id), &entity, nil)
+	return client.Get(ctx, fmt.Sprintf("%s/%v", resource, id), &entity, nil)
 }
 
 // Post sends a POST request, and does not expect the response to be enveloped
-func (client *Client) Post(url string, result interface{}, options *RequestOptions) error {
-	return client.SendRequest("POST", url, result, options)
+func (client *Client) Post(ctx context.Context, url string, result interface{}, options *RequestOptions) error {
+	return client.SendRequest(ctx, "POST", url, result, options)
+}
+
+// Put sends a PUT request, and does not expect the response to be enveloped
+func (client *Client) Put(ctx context.Context, url string, result interface{}, options *RequestOptions) error {
+	return client.SendRequest(ctx, "PUT", url, result, options)
+}
+
+// Patch sends a PATCH request, and does not expect the response to be
+// enveloped
+func (client *Client) Patch(ctx context.Context, url string, result interface{}, options *RequestOptions) error {
+	return client.SendRequest(ctx, "PATCH", url, result, options)
+}
+
+// Delete sends a DELETE request. Result may be nil if the response is of no
+// interest.
+func (client *Client) Delete(ctx context.Context, url string, result interface{}, options *RequestOptions) error {
+	return client.SendRequest(ctx, "DELETE", url, result, options)
+}
+
+// sendAPIEntity sends attributes as a JSON:API resource object of the given
+// type, and decodes the attributes of the returned entity into result, unless
+// it is nil
+func (client *Client) sendAPIEntity(ctx context.Context, method string, url string, entityType string,
+	id interface{}, attributes interface{}, result interface{}) error {
+	options := &RequestOptions{
+		Body: requestEnvelope{
+			Data: entityEnvelope{
+				ID:         id,
+				Type:       entityType,
+				Attributes: attributes,
+			},
+		},
+	}
+	if result == nil {
+		return client.SendRequest(ctx, method, url, nil, options)
+	}
+	entity := responseEnvelope{
+		Data: entityEnvelope{
+			Attributes: result,
+		},
+	}
+	return client.SendRequest(ctx, method, url, &entity, options)
+}
+
+// CreateAPIEntity is a general implementation for creating an object in an API
+// resource. The created object is decoded into result, unless it is nil.
+func (client *Client) CreateAPIEntity(ctx context.Context, resource string, entityType string,
+	attributes interface{}, result interface{}) error {
+	return client.sendAPIEntity(ctx, "POST", resource, entityType, nil, attributes, result)
+}
+
+// UpdateAPIEntity is a general implementation for updating an object of an API
+// resource. Only the given attributes are updated. The updated object is
+// decoded into result, unless it is nil.
+func (client *Client) UpdateAPIEntity(ctx context.Context, resource string, entityType string, id interface{},
+	attributes interface{}, result interface{}) error {
+	return client.sendAPIEntity(ctx, "PATCH", fmt.Sprintf("%s/%v", resource, id), entityType, id, attributes, result)
+}
+
+// DeleteAPIEntity is a general implementation for deleting an object of an API
+// resource
+func (client *Client) DeleteAPIEntity(ctx context.Context, resource string, id interface{}) error {
+	return client.Delete(ctx, fmt.Sprintf("%s/%v", resource, id), nil, nil)
 }
@@ -0,0 +1,23 @@
+package client
+
+import "testing"
+
+func TestGetFullURL(t *testing.T) {
+	client := NewClient("https://home", "key")
+	for _, test := range []struct {
+		url      string
+		options  RequestOptions
+		expected string
+	}{
+		{"clusters", RequestOptions{}, "https://home/api/v3/clusters"},
+		{"", RequestOptions{}, "https://home/api/v3"},
+		{"events/1", RequestOptions{Prefix: "api"}, "https://home/api/events/1"},
+		{"clusters", RequestOptions{Params: (&QueryParams{}).Set("a", 1)}, "https://home/api/v3/clusters?a=1"},
+		{"api/v3/status", RequestOptions{Prefix: RootPrefix}, "https://home/api/v3/status"},
+		{"", RequestOptions{Prefix: RootPrefix}, "https://home/"},
+	} {
+		if actual := client.getFullURL(test.url, &test.options); actual != test.expected {
+			t.Errorf("%q %q: expected %s, got %s", test.options.Prefix, test.url, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// Authentication types, as set in the "type" field of a site's auth
+// configuration
+const (
+	AuthTypeToken             = "token"
+	AuthTypeEnv               = "env"
+	AuthTypeCredentialProcess = "credential_process"
+)
+
+// DefaultAuthScheme is the Authorization header scheme expected by Weka Home
+const DefaultAuthScheme = "Token"
+
+// credentialExpiryMargin is how long before their expiry credentials
+// obtained from a credential process are refreshed
+const credentialExpiryMargin = time.Minute
+
+// Authenticator adds credentials to outgoing API requests
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *http.Request) error
+}
+
+func setAuthorizationHeader(req *http.Request, scheme string, token string) {
+	if scheme == "" {
+		scheme = DefaultAuthScheme
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s %s", scheme, token))
+}
+
+// StaticTokenAuth authenticates using a fixed token, e.g. an API key
+type StaticTokenAuth struct {
+	Token  string
+	Scheme string
+}
+
+func (auth *StaticTokenAuth) Authenticate(ctx context.Context, req *http.Request) error {
+	setAuthorizationHeader(req, auth.Scheme, auth.Token)
+	return nil
+}
+
+// EnvTokenAuth authenticates using a token read from an environment variable
+// on every request
+type EnvTokenAuth struct {
+	Variable string
+	Scheme   string
+}
+
+func (auth *EnvTokenAuth) Authenticate(ctx context.Context, req *http.Request) error {
+	token := os.Getenv(auth.Variable)
+	if token == "" {
+		return fmt.Errorf("environment variable %s is not set", auth.Variable)
+	}
+	setAuthorizationHeader(req, auth.Scheme, token)
+	return nil
+}
+
+// CredentialProcessAuth authenticates using a token printed by an external
+// command. The command may print either the bare token, or a JSON object of
+// the form {"token": "...", "expiration": "<RFC3339 time>"}. The token is
+// cached until shortly before its expiration, or for the lifetime of the
+// authenticator if it has none.
+type CredentialProcessAuth struct {
+	Command string
+	Scheme  string
+
+	mutex      sync.Mutex
+	token      string
+	expiration time.Time
+}
+
+type credentialProcessOutput struct {
+	Token      string    `json:"token"`
+	Expiration time.Time `json:"expiration"`
+}
+
+func (auth *CredentialProcessAuth) Authenticate(ctx context.Context, req *http.Request) error {
+	token, err := auth.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	setAuthorizationHeader(req, auth.Scheme, token)
+	return nil
+}
+
+func (auth *CredentialProcessAuth) getToken(ctx context.Context) (string, error) {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	if auth.token != "" &&
+		(auth.expiration.IsZero() || time.Now().Add(credentialExpiryMargin).Before(auth.expiration)) {
+		return auth.token, nil
+	}
+	logger.Debug().Str("command", auth.Command).Msg("Running credential process")
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", auth.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", auth.Command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("credential process failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	output = bytes.TrimSpace(output)
+	result := credentialProcessOutput{}
+	if len(output) != 0 && output[0] == '{' {
+		if err := json.Unmarshal(output, &result); err != nil {
+			return "", fmt.Errorf("failed to parse credential process output: %w", err)
+		}
+	} else {
+		result.Token = string(output)
+	}
+	if result.Token == "" {
+		return "", fmt.Errorf("credential process returned an empty token")
+	}
+	auth.token, auth.expiration = result.Token, result.Expiration
+	return auth.token, nil
+}
+
+// authenticatorFromConfig returns the Authenticator selected by a site's
+// configuration, defaulting to its API key
+func authenticatorFromConfig(siteConfig *env.SiteConfig) (Authenticator, error) {
+	authConfig := siteConfig.Auth
+	if authConfig == nil {
+		return &StaticTokenAuth{Token: siteConfig.APIKey}, nil
+	}
+	switch authConfig.Type {
+	case "", AuthTypeToken:
+		return &StaticTokenAuth{Token: siteConfig.APIKey, Scheme: authConfig.Scheme}, nil
+	case AuthTypeEnv:
+		if authConfig.EnvVar == "" {
+			return nil, fmt.Errorf("\"env_var\" must be set for auth type \"%s\"", AuthTypeEnv)
+		}
+		return &EnvTokenAuth{Variable: authConfig.EnvVar, Scheme: authConfig.Scheme}, nil
+	case AuthTypeCredentialProcess:
+		if authConfig.Command == "" {
+			return nil, fmt.Errorf("\"command\" must be set for auth type \"%s\"", AuthTypeCredentialProcess)
+		}
+		return &CredentialProcessAuth{Command: authConfig.Command, Scheme: authConfig.Scheme}, nil
+	}
+	return nil, fmt.Errorf("unknown auth type: \"%s\"", authConfig.Type)
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// authorization returns the Authorization header set by an authenticator
+func authorization(t *testing.T, auth Authenticator) (string, error) {
+	t.Helper()
+	req, err := http.NewRequest("GET", "http://home/api/v3/status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = auth.Authenticate(context.Background(), req)
+	return req.Header.Get("Authorization"), err
+}
+
+// credentialProcess returns a command printing output, and counting its runs
+// in a file whose name it also returns
+func credentialProcess(t *testing.T, output string) (string, string) {
+	t.Helper()
+	countFile := filepath.Join(t.TempDir(), "count")
+	return fmt.Sprintf("echo run >> '%s'; echo '%s'", countFile, output), countFile
+}
+
+func numRuns(t *testing.T, countFile string) int {
+	t.Helper()
+	data, err := os.ReadFile(countFile)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "run\n")
+}
+
+func TestStaticTokenAuth(t *testing.T) {
+	header, err := authorization(t, &StaticTokenAuth{Token: "key"})
+	if err != nil || header != "Token key" {
+		t.Errorf("unexpected header %q, %v", header, err)
+	}
+	header, err = authorization(t, &StaticTokenAuth{Token: "key", Scheme: "Bearer"})
+	if err != nil || header != "Bearer key" {
+		t.Errorf("unexpected header %q, %v", header, err)
+	}
+}
+
+func TestEnvTokenAuth(t *testing.T) {
+	auth := &EnvTokenAuth{Variable: "HOMECLI_TEST_TOKEN", Scheme: "Bearer"}
+	t.Setenv("HOMECLI_TEST_TOKEN", "")
+	if _, err := authorization(t, auth); err == nil || !strings.Contains(err.Error(), "HOMECLI_TEST_TOKEN is not set") {
+		t.Errorf("expected a missing variable error, got %v", err)
+	}
+	// The variable is read on every request
+	for _, token := range []string{"first", "second"} {
+		t.Setenv("HOMECLI_TEST_TOKEN", token)
+		header, err := authorization(t, auth)
+		if err != nil || header != "Bearer "+token {
+			t.Errorf("unexpected header %q, %v", header, err)
+		}
+	}
+}
+
+func TestCredentialProcessAuth(t *testing.T) {
+	command, countFile := credentialProcess(t, "  bare-token ")
+	auth := &CredentialProcessAuth{Command: command}
+	for i := 0; i < 3; i++ {
+		header, err := authorization(t, auth)
+		if err != nil || header != "Token bare-token" {
+			t.Errorf("unexpected header %q, %v", header, err)
+		}
+	}
+	// Tokens without an expiration are cached for good
+	if runs := numRuns(t, countFile); runs != 1 {
+		t.Errorf("expected the command to run once, ran %d times", runs)
+	}
+}
+
+func TestCredentialProcessAuthExpiration(t *testing.T) {
+	for expiresIn, expectedRuns := range map[time.Duration]int{
+		time.Hour: 1,
+		// Tokens are refreshed when they are about to expire
+		credentialExpiryMargin / 2: 3,
+		-time.Hour:                 3,
+	} {
+		expiration := time.Now().Add(expiresIn).UTC().Format(time.RFC3339)
+		command, countFile := credentialProcess(t,
+			fmt.Sprintf(`{"token": "json-token", "expiration": "%s"}`, expiration))
+		auth := &CredentialProcessAuth{Command: command, Scheme: "Bearer"}
+		for i := 0; i < 3; i++ {
+			header, err := authorization(t, auth)
+			if err != nil || header != "Bearer json-token" {
+				t.Errorf("unexpected header %q, %v", header, err)
+			}
+		}
+		if runs := numRuns(t, countFile); runs != expectedRuns {
+			t.Errorf("expiring in %s: expected the command to run %d times, ran %d times", expiresIn, expectedRuns, runs)
+		}
+	}
+}
+
+func TestCredentialProcessAuthErrors(t *testing.T) {
+	for command, expected := range map[string]string{
+		"echo 'no such token' >&2; exit 3": "credential process failed: exit status 3: no such token",
+		"echo":                             "credential process returned an empty token",
+		`echo '{"token": ""}'`:             "credential process returned an empty token",
+		`echo '{"token": 1}'`:              "failed to parse credential process output",
+	} {
+		auth := &CredentialProcessAuth{Command: command}
+		if _, err := authorization(t, auth); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", command, expected, err)
+		}
+	}
+	// Failures are not cached
+	command, countFile := credentialProcess(t, "")
+	auth := &CredentialProcessAuth{Command: command}
+	authorization(t, auth)
+	authorization(t, auth)
+	if runs := numRuns(t, countFile); runs != 2 {
+		t.Errorf("expected the command to run again after failing, ran %d times", runs)
+	}
+}
+
+func TestAuthenticatorFromConfig(t *testing.T) {
+	for _, test := range []struct {
+		auth     *env.AuthConfig
+		expected Authenticator
+	}{
+		{nil, &StaticTokenAuth{Token: "key"}},
+		{&env.AuthConfig{Scheme: "Bearer"}, &StaticTokenAuth{Token: "key", Scheme: "Bearer"}},
+		{&env.AuthConfig{Type: AuthTypeToken}, &StaticTokenAuth{Token: "key"}},
+		{&env.AuthConfig{Type: AuthTypeEnv, EnvVar: "TOKEN"}, &EnvTokenAuth{Variable: "TOKEN"}},
+		{&env.AuthConfig{Type: AuthTypeCredentialProcess, Command: "mint", Scheme: "Bearer"},
+			&CredentialProcessAuth{Command: "mint", Scheme: "Bearer"}},
+	} {
+		auth, err := authenticatorFromConfig(&env.SiteConfig{APIKey: "key", Auth: test.auth})
+		if err != nil {
+			t.Errorf("%+v: %s", test.auth, err)
+			continue
+		}
+		if fmt.Sprintf("%#v", auth) != fmt.Sprintf("%#v", test.expected) {
+			t.Errorf("%+v: expected %#v, got %#v", test.auth, test.expected, auth)
+		}
+	}
+	for _, test := range []struct {
+		auth     *env.AuthConfig
+		expected string
+	}{
+		{&env.AuthConfig{Type: AuthTypeEnv}, `"env_var" must be set`},
+		{&env.AuthConfig{Type: AuthTypeCredentialProcess}, `"command" must be set`},
+		{&env.AuthConfig{Type: "oauth"}, `unknown auth type: "oauth"`},
+	} {
+		_, err := authenticatorFromConfig(&env.SiteConfig{Auth: test.auth})
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%+v: expected an error containing %q, got %v", test.auth, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// DefaultCacheTTLs are the per-resource TTLs used by the CLI unless overridden
+// in the site configuration. Resources without a TTL are always revalidated.
+var DefaultCacheTTLs = map[string]time.Duration{
+	"customers": time.Hour,
+}
+
+// DefaultCacheMaxAge and DefaultCacheMaxSize bound the cache of the CLI unless
+// overridden in the site configuration
+const (
+	DefaultCacheMaxAge  = 7 * 24 * time.Hour
+	DefaultCacheMaxSize = 100 << 20
+)
+
+// ResponseCache stores GET responses on disk. Cached responses younger than
+// their TTL are served without contacting the server; older ones are
+// revalidated using their ETag and Last-Modified headers. Responses with
+// neither a TTL nor a validator are never reused, so they are not stored.
+// Entries are keyed by the credentials of the request as well as its URL, so
+// that responses are only served to the identity they were sent to.
+type ResponseCache struct {
+	Dir        string
+	DefaultTTL time.Duration
+	// TTLs overrides DefaultTTL for requests whose URL (relative to the API
+	// prefix) starts with the given resource path, e.g. "customers". The
+	// longest matching resource path wins.
+	TTLs map[string]time.Duration
+	// MaxAge and MaxSize bound the entries kept on disk, see Prune. Zero
+	// means no bound.
+	MaxAge  time.Duration
+	MaxSize int64
+}
+
+type cacheEntry struct {
+	URL string `json:"url"`
+	// Identity is a hash of the credentials the response was sent to
+	Identity     string          `json:"identity"`
+	StoredAt     time.Time       `json:"stored_at"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// NewResponseCache returns a cache storing responses under dir
+func NewResponseCache(dir string) *ResponseCache {
+	return &ResponseCache{
+		Dir:     dir,
+		TTLs:    make(map[string]time.Duration),
+		MaxAge:  DefaultCacheMaxAge,
+		MaxSize: DefaultCacheMaxSize,
+	}
+}
+
+// SiteCacheDir returns the cache directory used by the CLI for a site
+func SiteCacheDir(siteName string) string {
+	return filepath.Join(env.ConfigDir, "cache", siteName)
+}
+
+// TTL returns how long a response to a request for the given relative URL is
+// served from the cache without revalidation
+func (cache *ResponseCache) TTL(url string) time.Duration {
+	path := strings.Trim(strings.SplitN(url, "?", 2)[0], "/")
+	ttl, longestMatch := cache.DefaultTTL, -1
+	for resource, resourceTTL := range cache.TTLs {
+		resource = strings.Trim(resource, "/")
+		if (path == resource || strings.HasPrefix(path, resource+"/")) && len(resource) > longestMatch {
+			ttl, longestMatch = resourceTTL, len(resource)
+		}
+	}
+	return ttl
+}
+
+// Clear removes all cached responses
+func (cache *ResponseCache) Clear() error {
+	return os.RemoveAll(cache.Dir)
+}
+
+// Prune removes entries stored longer than MaxAge ago, and then the least
+// recently stored entries until the entries take at most MaxSize bytes
+func (cache *ResponseCache) Prune() error {
+	dirEntries, err := os.ReadDir(cache.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	type entryFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []entryFile
+	var totalSize int64
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), ".json") {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(cache.Dir, dirEntry.Name())
+		if cache.MaxAge > 0 && time.Since(info.ModTime()) > cache.MaxAge {
+			os.Remove(path)
+			continue
+		}
+		files = append(files, entryFile{path, info.Size(), info.ModTime()})
+		totalSize += info.Size()
+	}
+	if cache.MaxSize <= 0 || totalSize <= cache.MaxSize {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, file := range files {
+		if totalSize <= cache.MaxSize {
+			break
+		}
+		if err := os.Remove(file.path); err == nil {
+			totalSize -= file.size
+		}
+	}
+	return nil
+}
+
+// credentialsIdentity returns the identity of the credentials of a request,
+// see cacheEntry.Identity
+func credentialsIdentity(header http.Header) string {
+	hash := sha256.Sum256([]byte(header.Get("Authorization")))
+	return hex.EncodeToString(hash[:])
+}
+
+func (cache *ResponseCache) entryPath(identity string, fullURL string) string {
+	hash := sha256.Sum256([]byte(identity + " " + fullURL))
+	return filepath.Join(cache.Dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// load returns the cached entry for a URL and identity, or nil if there is
+// none
+func (cache *ResponseCache) load(identity string, fullURL string) *cacheEntry {
+	data, err := os.ReadFile(cache.entryPath(identity, fullURL))
+	if err != nil {
+		return nil
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil || entry.URL != fullURL || entry.Identity != identity {
+		logger.Debug().Str("url", fullURL).Msg("Ignoring invalid cache entry")
+		return nil
+	}
+	return entry
+}
+
+// remove removes the cached entry for a URL and identity, if any
+func (cache *ResponseCache) remove(identity string, fullURL string) {
+	os.Remove(cache.entryPath(identity, fullURL))
+}
+
+// removeResource removes the cached entries of a resource, for all
+// identities: the entries of resourceURL itself, of its pages, and of the
+// resources nested under it
+func (cache *ResponseCache) removeResource(resourceURL string) {
+	paths, err := filepath.Glob(filepath.Join(cache.Dir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		entry := struct {
+			URL string `json:"url"`
+		}{}
+		if json.Unmarshal(data, &entry) != nil || entry.URL == resourceURL ||
+			strings.HasPrefix(entry.URL, resourceURL+"/") || strings.HasPrefix(entry.URL, resourceURL+"?") {
+			os.Remove(path)
+		}
+	}
+}
+
+// store writes an entry atomically, so that concurrent readers never see a
+// partially written entry
+func (cache *ResponseCache) store(entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cache.Dir, 0700); err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(cache.Dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), cache.entryPath(entry.Identity, entry.URL))
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+func (entry *cacheEntry) isFresh(ttl time.Duration) bool {
+	return ttl > 0 && time.Since(entry.StoredAt) < ttl
+}
+
+// isReusable returns whether the entry can ever be served, either while
+// fresh or after revalidation
+func (entry *cacheEntry) isReusable(ttl time.Duration) bool {
+	return ttl > 0 || entry.ETag != "" || entry.LastModified != ""
+}
+
+// setConditionalHeaders makes a request conditional on the cached response
+// being outdated
+func (entry *cacheEntry) setConditionalHeaders(header http.Header) {
+	if entry.ETag != "" {
+		header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+func newCacheEntry(identity string, fullURL string, res *http.Response, body []byte) *cacheEntry {
+	return &cacheEntry{
+		URL:          fullURL,
+		Identity:     identity,
+		StoredAt:     time.Now(),
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		Body:         body,
+	}
+}
+
+// responseCacheFromConfig returns the response cache of a site, or nil if
+// caching is disabled
+func responseCacheFromConfig(siteName string, config *env.CacheConfig) (*ResponseCache, error) {
+	cache := NewResponseCache(SiteCacheDir(siteName))
+	for resource, ttl := range DefaultCacheTTLs {
+		cache.TTLs[resource] = ttl
+	}
+	if config == nil {
+		return cache, nil
+	}
+	if config.Disabled {
+		return nil, nil
+	}
+	if config.DefaultTTL != "" {
+		ttl, err := time.ParseDuration(config.DefaultTTL)
+		if err != nil {
+			return nil, fmt.Errorf("default_ttl: %s", err)
+		}
+		cache.DefaultTTL = ttl
+	}
+	if config.MaxAge != "" {
+		maxAge, err := time.ParseDuration(config.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("max_age: %s", err)
+		}
+		cache.MaxAge = maxAge
+	}
+	if config.MaxSizeMB != 0 {
+		cache.MaxSize = int64(config.MaxSizeMB) << 20
+	}
+	for resource, ttlText := range config.TTLs {
+		ttl, err := time.ParseDuration(ttlText)
+		if err != nil {
+			return nil, fmt.Errorf("ttls.%s: %s", resource, err)
+		}
+		cache.TTLs[resource] = ttl
+	}
+	return cache, nil
+}
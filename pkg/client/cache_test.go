@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCachingServer returns a server responding with the given validator
+// headers, and 304 Not Modified to requests matching them. It counts full
+// responses and 304 responses.
+func newCachingServer(t *testing.T, header http.Header) (server *httptest.Server, full *atomic.Int32,
+	notModified *atomic.Int32) {
+	t.Helper()
+	full, notModified = &atomic.Int32{}, &atomic.Int32{}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := header.Get("ETag")
+		if (etag != "" && r.Header.Get("If-None-Match") == etag) ||
+			(etag == "" && header.Get("Last-Modified") != "" && r.Header.Get("If-Modified-Since") != "") {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"acme"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, full, notModified
+}
+
+func getName(t *testing.T, client *Client, options *RequestOptions) string {
+	t.Helper()
+	return getNameOf(t, client, "customers/1", options)
+}
+
+func getNameOf(t *testing.T, client *Client, url string, options *RequestOptions) string {
+	t.Helper()
+	result := struct{ Name string }{}
+	if err := client.Get(context.Background(), url, &result, options); err != nil {
+		t.Fatal(err)
+	}
+	return result.Name
+}
+
+func numCacheEntries(t *testing.T, cache *ResponseCache) int {
+	t.Helper()
+	entries, err := filepath.Glob(filepath.Join(cache.Dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	for name, header := range map[string]http.Header{
+		"etag":          {"Etag": {`"v1"`}},
+		"last modified": {"Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, full, notModified := newCachingServer(t, header)
+			client := NewClient(server.URL, "key")
+			client.Cache = NewResponseCache(t.TempDir())
+			for i := 0; i < 3; i++ {
+				if name := getName(t, client, nil); name != "acme" {
+					t.Fatalf("expected acme, got %q", name)
+				}
+			}
+			if full.Load() != 1 || notModified.Load() != 2 {
+				t.Errorf("expected 1 full response and 2 revalidations, got %d and %d", full.Load(), notModified.Load())
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	server, full, _ := newCachingServer(t, http.Header{})
+	client := NewClient(server.URL, "key")
+	client.Cache = NewResponseCache(t.TempDir())
+	client.Cache.TTLs["customers"] = time.Hour
+	getName(t, client, nil)
+	getName(t, client, nil)
+	if full.Load() != 1 {
+		t.Errorf("expected a single request, got %d", full.Load())
+	}
+	getName(t, client, &RequestOptions{NoCache: true})
+	if full.Load() != 2 {
+		t.Errorf("expected NoCache to bypass the cache, got %d requests", full.Load())
+	}
+}
+
+func TestCacheSkipsUnusableResponses(t *testing.T) {
+	server, full, _ := newCachingServer(t, http.Header{})
+	client := NewClient(server.URL, "key")
+	client.Cache = NewResponseCache(t.TempDir())
+	getName(t, client, nil)
+	getName(t, client, nil)
+	if full.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", full.Load())
+	}
+	if n := numCacheEntries(t, client.Cache); n != 0 {
+		t.Errorf("expected responses without a TTL or validator not to be stored, got %d entries", n)
+	}
+}
+
+func TestCacheInvalidatedByWrites(t *testing.T) {
+	server, full, _ := newCachingServer(t, http.Header{})
+	client := NewClient(server.URL, "key")
+	client.Cache = NewResponseCache(t.TempDir())
+	client.Cache.DefaultTTL = time.Hour
+	// Writes invalidate the whole resource: the collection, its pages and
+	// its entities, but not other resources
+	urls := []string{"customers/1", "customers?page=1&page_size=50", "customers", "customers/2/clusters"}
+	for _, url := range append(urls, "clusters") {
+		getNameOf(t, client, url, nil)
+	}
+	if err := client.Patch(context.Background(), "customers/1", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range append(urls, "clusters") {
+		getNameOf(t, client, url, nil)
+	}
+	if expected := int32(2*len(urls) + 2); full.Load() != expected {
+		t.Errorf("expected the write to invalidate %d cached responses, got %d requests", len(urls), full.Load())
+	}
+}
+
+func TestCacheKeyedByIdentity(t *testing.T) {
+	server, full, _ := newCachingServer(t, http.Header{})
+	client := NewClient(server.URL, "")
+	client.Authenticator = &EnvTokenAuth{Variable: "HOMECLI_TEST_TOKEN"}
+	client.Cache = NewResponseCache(t.TempDir())
+	client.Cache.DefaultTTL = time.Hour
+	for _, token := range []string{"first", "second", "first", "second"} {
+		t.Setenv("HOMECLI_TEST_TOKEN", token)
+		getName(t, client, nil)
+	}
+	if full.Load() != 2 {
+		t.Errorf("expected a request per identity, got %d requests", full.Load())
+	}
+	if entries := numCacheEntries(t, client.Cache); entries != 2 {
+		t.Errorf("expected an entry per identity, got %d entries", entries)
+	}
+	data, err := os.ReadFile(client.Cache.entryPath(credentialsIdentity(http.Header{"Authorization": {"Token first"}}),
+		server.URL+"/api/v3/customers/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "first") {
+		t.Errorf("expected the credentials not to be stored in the cache")
+	}
+}
+
+func TestCacheTTLs(t *testing.T) {
+	cache := NewResponseCache(t.TempDir())
+	cache.DefaultTTL = time.Minute
+	cache.TTLs["customers"] = time.Hour
+	cache.TTLs["customers/1/clusters"] = time.Second
+	for url, expected := range map[string]time.Duration{
+		"clusters":                  time.Minute,
+		"customers":                 time.Hour,
+		"customers?page=2":          time.Hour,
+		"customers/1":               time.Hour,
+		"customers/1/clusters":      time.Second,
+		"customers_archive":         time.Minute,
+		"/customers/1/clusters/2/x": time.Second,
+	} {
+		if ttl := cache.TTL(url); ttl != expected {
+			t.Errorf("%s: expected %s, got %s", url, expected, ttl)
+		}
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	cache := NewResponseCache(t.TempDir())
+	now := time.Now()
+	for i, age := range []time.Duration{30 * 24 * time.Hour, 3 * time.Hour, 2 * time.Hour, time.Hour} {
+		entry := &cacheEntry{URL: string(rune('a' + i)), StoredAt: now.Add(-age), Body: []byte(`{}`)}
+		if err := cache.store(entry); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(cache.entryPath("", entry.URL), now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := os.Stat(cache.entryPath("", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Room for two entries
+	cache.MaxSize = 2 * info.Size()
+	if err := cache.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	for url, kept := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		if (cache.load("", url) != nil) != kept {
+			t.Errorf("%s: expected kept to be %v", url, kept)
+		}
+	}
+}
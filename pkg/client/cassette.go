@@ -0,0 +1,243 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// redactedValue replaces the values of sensitive headers in cassettes
+const redactedValue = "REDACTED"
+
+// sensitiveHeaders are redacted before interactions are written to a cassette
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// ErrNoRecordedResponse is returned by ReplayingTransport for requests that
+// are missing from its cassette
+var ErrNoRecordedResponse = errors.New("no recorded response")
+
+// Interaction is a single recorded request/response pair. Cassette files hold
+// one JSON encoded interaction per line.
+type Interaction struct {
+	Request struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header,omitempty"`
+		Body   string      `json:"body,omitempty"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int         `json:"status_code"`
+		Header     http.Header `json:"header,omitempty"`
+		// Body is stored as is if it is valid UTF-8, and base64 encoded
+		// otherwise, as indicated by BodyEncoding
+		Body         string `json:"body,omitempty"`
+		BodyEncoding string `json:"body_encoding,omitempty"`
+	} `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Duration   float64   `json:"duration_seconds"`
+}
+
+func redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range sensitiveHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redactedValue)
+		}
+	}
+	return header
+}
+
+// RecordingTransport is an http.RoundTripper that sends requests using Base
+// and appends every request/response pair to a cassette file, with sensitive
+// headers redacted
+type RecordingTransport struct {
+	Base http.RoundTripper
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewRecordingTransport creates (or truncates) a cassette file and returns a
+// transport recording into it. If base is nil, http.DefaultTransport is used.
+func NewRecordingTransport(fileName string, base http.RoundTripper) (*RecordingTransport, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cassette: %w", err)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RecordingTransport{Base: base, file: file}, nil
+}
+
+func (transport *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	interaction := &Interaction{RecordedAt: time.Now().UTC()}
+	interaction.Request.Method = req.Method
+	interaction.Request.URL = req.URL.String()
+	interaction.Request.Header = redactHeader(req.Header)
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			bodyBytes, _ := io.ReadAll(body)
+			interaction.Request.Body = string(bodyBytes)
+		}
+	}
+	res, err := transport.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(responseBody))
+	interaction.Duration = time.Since(interaction.RecordedAt).Seconds()
+	interaction.Response.StatusCode = res.StatusCode
+	interaction.Response.Header = redactHeader(res.Header)
+	if utf8.Valid(responseBody) {
+		interaction.Response.Body = string(responseBody)
+	} else {
+		interaction.Response.Body = base64.StdEncoding.EncodeToString(responseBody)
+		interaction.Response.BodyEncoding = "base64"
+	}
+	if err := transport.write(interaction); err != nil {
+		logger.Error().Err(err).Msg("Failed to record interaction")
+	}
+	return res, nil
+}
+
+func (transport *RecordingTransport) write(interaction *Interaction) error {
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	_, err = transport.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the cassette file
+func (transport *RecordingTransport) Close() error {
+	return transport.file.Close()
+}
+
+// ReplayingTransport is an http.RoundTripper that serves responses from a
+// cassette, without any network access. Requests are matched by method, path
+// and query, regardless of the host they were recorded against; repeated
+// identical requests are served the recorded responses in order, the last one
+// being reused once exhausted.
+type ReplayingTransport struct {
+	mutex        sync.Mutex
+	interactions map[string][]*Interaction
+}
+
+// NewReplayingTransport loads a cassette file recorded by RecordingTransport
+func NewReplayingTransport(fileName string) (*ReplayingTransport, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %w", err)
+	}
+	defer file.Close()
+	transport := &ReplayingTransport{interactions: make(map[string][]*Interaction)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<30)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		interaction := &Interaction{}
+		if err := json.Unmarshal(line, interaction); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s, line %d: %w", fileName, lineNumber, err)
+		}
+		recordedURL, err := url.Parse(interaction.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cassette %s, line %d: %w", fileName, lineNumber, err)
+		}
+		key := interactionKey(interaction.Request.Method, recordedURL)
+		transport.interactions[key] = append(transport.interactions[key], interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	return transport, nil
+}
+
+func interactionKey(method string, requestURL *url.URL) string {
+	return strings.ToUpper(method) + " " + requestURL.RequestURI()
+}
+
+func (transport *ReplayingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := interactionKey(req.Method, req.URL)
+	transport.mutex.Lock()
+	interactions := transport.interactions[key]
+	if len(interactions) == 0 {
+		transport.mutex.Unlock()
+		return nil, fmt.Errorf("%w for %s", ErrNoRecordedResponse, key)
+	}
+	interaction := interactions[0]
+	if len(interactions) > 1 {
+		transport.interactions[key] = interactions[1:]
+	}
+	transport.mutex.Unlock()
+	body := []byte(interaction.Response.Body)
+	if interaction.Response.BodyEncoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(interaction.Response.Body); err != nil {
+			return nil, fmt.Errorf("invalid recorded response body for %s: %w", key, err)
+		}
+	}
+	header := interaction.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+var (
+	cliTransportOnce sync.Once
+	cliTransport     http.RoundTripper
+	cliTransportErr  error
+)
+
+// getCLITransport returns the transport selected by the --record or --replay
+// command line flags, or nil if neither is set. The transport is shared by
+// all clients, so that a single cassette covers the whole command. Recorded
+// requests are sent using base, or http.DefaultTransport if it is nil.
+func getCLITransport(base http.RoundTripper) (http.RoundTripper, error) {
+	cliTransportOnce.Do(func() {
+		if env.ReplayFile != "" {
+			cliTransport, cliTransportErr = NewReplayingTransport(env.ReplayFile)
+		} else if env.RecordFile != "" {
+			cliTransport, cliTransportErr = NewRecordingTransport(env.RecordFile, base)
+		}
+	})
+	return cliTransport, cliTransportErr
+}
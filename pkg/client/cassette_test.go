@@ -0,0 +1,132 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+// recordedCalls makes the requests recorded and replayed by the cassette
+// tests, and returns a summary of their results
+func recordedCalls(t *testing.T, api *client.Client, dir string) string {
+	t.Helper()
+	ctx := context.Background()
+	cluster, err := api.GetCluster(ctx, fakehome.ActiveClusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := api.QueryEvents(ctx, fakehome.ActiveClusterID, &client.EventQueryOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := query.Collect(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(dir, "diags.bin")
+	url := fmt.Sprintf("clusters/%s/support/files/diags-backend-0.tar.gz/content", fakehome.ActiveClusterID)
+	if err := api.Download(ctx, url, fileName, nil); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%s %s %s %s %x", cluster.Name, events[0].ID, events[1].ID, events[2].ID, content)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	// Binary responses are base64 encoded in cassettes
+	server.WithFixtures(func(fixtures *fakehome.Fixtures) {
+		fixtures.DiagContents["diags-backend-0.tar.gz"] = "\x1f\x8b\x08\x00\xff\xfe"
+	})
+	cassette := filepath.Join(t.TempDir(), "test.cassette")
+
+	recorder, err := client.NewRecordingTransport(cassette, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := server.Client()
+	api.HTTPClient.Transport = recorder
+	recorded := recordedCalls(t, api, t.TempDir())
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), server.APIKey) || !strings.Contains(string(data), "REDACTED") {
+		t.Errorf("expected the API key to be redacted from the cassette")
+	}
+	if !strings.Contains(string(data), `"body_encoding":"base64"`) {
+		t.Errorf("expected the binary response to be base64 encoded")
+	}
+
+	// Responses are replayed regardless of the recorded host
+	server.Close()
+	replayer, err := client.NewReplayingTransport(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api = client.NewClient("http://replay.invalid", "another-key")
+	api.HTTPClient.Transport = replayer
+	replayed := recordedCalls(t, api, t.TempDir())
+	if replayed != recorded {
+		t.Errorf("expected the replayed results %q to match the recorded ones %q", replayed, recorded)
+	}
+
+	_, err = api.GetCluster(context.Background(), fakehome.InactiveClusterID)
+	if !errors.Is(err, client.ErrNoRecordedResponse) {
+		t.Errorf("expected a missing recording error, got %v", err)
+	}
+}
+
+func TestReplayRepeatedRequests(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "test.cassette")
+	lines := []string{
+		`{"request":{"method":"GET","url":"http://a/api/v3/status"},"response":{"status_code":503}}`,
+		`{"request":{"method":"GET","url":"http://a/api/v3/status"},"response":{"status_code":200,"body":"{\"version\":\"1\"}"}}`,
+	}
+	if err := os.WriteFile(cassette, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := client.NewReplayingTransport(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := client.NewClient("http://b", "key")
+	api.HTTPClient.Transport = replayer
+	api.RetryPolicy.MaxAttempts = 1
+	ctx := context.Background()
+	if _, err := api.GetServerStatus(ctx); !client.HasStatus(err, 503) {
+		t.Errorf("expected the first recorded response, got %v", err)
+	}
+	// The last response is reused once the recorded ones are exhausted
+	for i := 0; i < 2; i++ {
+		status, err := api.GetServerStatus(ctx)
+		if err != nil || status.Version != "1" {
+			t.Errorf("expected the second recorded response, got %v, %v", status, err)
+		}
+	}
+}
+
+func TestReplayInvalidCassette(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "test.cassette")
+	if err := os.WriteFile(cassette, []byte("{}\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.NewReplayingTransport(cassette)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+}
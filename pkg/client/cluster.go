@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -26,29 +27,66 @@ type Cluster struct {
 }
 
 // GetCluster returns a single cluster
-func (client *Client) GetCluster(id string) (*Cluster, error) {
+func (client *Client) GetCluster(ctx context.Context, id string) (*Cluster, error) {
 	logger.Info().Str("id", id).Msg("Fetching cluster")
 	cluster := &Cluster{}
-	err := client.GetAPIEntity("clusters", id, cluster)
+	err := client.GetAPIEntity(ctx, "clusters", id, cluster)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch cluster %s: %s", id, err)
+		return nil, fmt.Errorf("could not fetch cluster %s: %w", id, err)
 	}
 	return cluster, nil
 }
 
-func (client *Client) GetClusterCustomer(cluster *Cluster) (*Customer, error) {
+// clusterMuteAttributes are the cluster attributes updated when muting or
+// unmuting a cluster. A nil MuteTime is sent as null, clearing the time the
+// cluster was muted until.
+type clusterMuteAttributes struct {
+	Muted    bool       `json:"muted"`
+	MuteTime *time.Time `json:"mute_time"`
+}
+
+// MuteCluster mutes the alerts of a cluster until the given time, or until it
+// is unmuted if until is zero, and returns the updated cluster
+func (client *Client) MuteCluster(ctx context.Context, id string, until time.Time) (*Cluster, error) {
+	logger.Info().Str("id", id).Time("until", until).Msg("Muting cluster")
+	cluster := &Cluster{}
+	attributes := clusterMuteAttributes{Muted: true}
+	if !until.IsZero() {
+		until = until.UTC()
+		attributes.MuteTime = &until
+	}
+	err := client.UpdateAPIEntity(ctx, "clusters", "cluster", id, attributes, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("could not mute cluster %s: %w", id, err)
+	}
+	return cluster, nil
+}
+
+// UnmuteCluster unmutes the alerts of a cluster, and returns the updated
+// cluster
+func (client *Client) UnmuteCluster(ctx context.Context, id string) (*Cluster, error) {
+	logger.Info().Str("id", id).Msg("Unmuting cluster")
+	cluster := &Cluster{}
+	err := client.UpdateAPIEntity(ctx, "clusters", "cluster", id, clusterMuteAttributes{}, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmute cluster %s: %w", id, err)
+	}
+	return cluster, nil
+}
+
+func (client *Client) GetClusterCustomer(ctx context.Context, cluster *Cluster) (*Customer, error) {
 	if len(cluster.CustomerID) == 0 {
 		return nil, fmt.Errorf("Cluster %s has no customer", cluster.ID)
 	}
-	customer, err := client.GetCustomer(cluster.CustomerID)
+	customer, err := client.GetCustomer(ctx, cluster.CustomerID)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch customer for cluster %s: %s", cluster.ID, err)
+		return nil, fmt.Errorf("could not fetch customer for cluster %s: %w", cluster.ID, err)
 	}
 	return customer, nil
 }
 
-func (client *Client) QueryClusters(options *RequestOptions) (*PagedQuery, error) {
-	query, err := client.QueryEntities("clusters", options)
+func (client *Client) QueryClusters(ctx context.Context, options *RequestOptions) (*Query[Cluster], error) {
+	query, err := QueryEntitiesOf[Cluster](ctx, client, "clusters", options)
 	if err != nil {
 		return nil, err
 	}
@@ -62,14 +100,9 @@ func GetActiveClustersParams() *QueryParams {
 		Set("monitored", "true")
 }
 
+// NextCluster returns the next cluster, or nil if there are no more clusters.
+//
+// Deprecated: use Query[Cluster].Next instead.
 func (query *PagedQuery) NextCluster() (*Cluster, error) {
-	cluster := &Cluster{}
-	ok, err := query.NextEntity(cluster)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get next cluster: %s", err)
-	}
-	if !ok {
-		return nil, nil
-	}
-	return cluster, nil
+	return nextEntityOf[Cluster](query, "cluster")
 }
@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestMuteCluster(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	var bodies []string
+	api.Use(func(req *http.Request, next client.RoundTripFunc) (*http.Response, error) {
+		if req.GetBody != nil {
+			body, _ := req.GetBody()
+			data, _ := io.ReadAll(body)
+			bodies = append(bodies, string(data))
+		}
+		return next(req)
+	})
+	ctx := context.Background()
+	until := time.Date(2030, 1, 2, 18, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	cluster, err := api.MuteCluster(ctx, fakehome.ActiveClusterID, until)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cluster.Muted || !cluster.MuteTime.Equal(until) {
+		t.Errorf("unexpected muted cluster %+v", cluster)
+	}
+	if !strings.Contains(bodies[0], `"mute_time":"2030-01-02T16:00:00Z"`) {
+		t.Errorf("expected the mute time to be sent in UTC, got %s", bodies[0])
+	}
+
+	cluster, err = api.UnmuteCluster(ctx, fakehome.ActiveClusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.Muted || !cluster.MuteTime.IsZero() {
+		t.Errorf("unexpected unmuted cluster %+v", cluster)
+	}
+	if !strings.Contains(bodies[1], `"mute_time":null`) {
+		t.Errorf("expected the mute time to be cleared, got %s", bodies[1])
+	}
+
+	// Muting without a time mutes until unmuted
+	cluster, err = api.MuteCluster(ctx, fakehome.ActiveClusterID, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cluster.Muted || !cluster.MuteTime.IsZero() {
+		t.Errorf("unexpected muted cluster %+v", cluster)
+	}
+	if _, err := api.MuteCluster(ctx, "no-such-cluster", time.Time{}); !client.IsNotFound(err) {
+		t.Errorf("expected a missing cluster not to be found, got %v", err)
+	}
+}
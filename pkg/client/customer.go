@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -16,18 +17,18 @@ type Customer struct {
 }
 
 // GetCustomer returns a single customer
-func (client *Client) GetCustomer(id string) (*Customer, error) {
+func (client *Client) GetCustomer(ctx context.Context, id string) (*Customer, error) {
 	logger.Info().Str("id", id).Msg("Fetching customer")
 	customer := &Customer{}
-	err := client.GetAPIEntity("customers", id, customer)
+	err := client.GetAPIEntity(ctx, "customers", id, customer)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch customer %s: %s", id, err)
+		return nil, fmt.Errorf("could not fetch customer %s: %w", id, err)
 	}
 	return customer, nil
 }
 
-func (client *Client) QueryCustomers() (*PagedQuery, error) {
-	query, err := client.QueryEntities("customers", &RequestOptions{
+func (client *Client) QueryCustomers(ctx context.Context) (*Query[Customer], error) {
+	query, err := QueryEntitiesOf[Customer](ctx, client, "customers", &RequestOptions{
 		NoAutoFetchNextPage: true,
 	})
 	if err != nil {
@@ -36,14 +37,9 @@ func (client *Client) QueryCustomers() (*PagedQuery, error) {
 	return query, nil
 }
 
+// NextCustomer returns the next customer, or nil if there are no more customers.
+//
+// Deprecated: use Query[Customer].Next instead.
 func (query *PagedQuery) NextCustomer() (*Customer, error) {
-	customer := &Customer{}
-	ok, err := query.NextEntity(customer)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch next customer: %s", err.Error())
-	}
-	if !ok {
-		return nil, nil
-	}
-	return customer, nil
+	return nextEntityOf[Customer](query, "customer")
 }
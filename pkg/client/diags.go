@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -18,8 +19,8 @@ type Diag struct {
 	UploadTime time.Time `json:"upload_time"`
 }
 
-func (client *Client) QueryDiags(clusterID string, options *RequestOptions) (*PagedQuery, error) {
-	query, err := client.QueryEntities(
+func (client *Client) QueryDiags(ctx context.Context, clusterID string, options *RequestOptions) (*Query[Diag], error) {
+	query, err := QueryEntitiesOf[Diag](ctx, client,
 		fmt.Sprintf("clusters/%s/support/files", clusterID),
 		options)
 	if err != nil {
@@ -28,33 +29,29 @@ func (client *Client) QueryDiags(clusterID string, options *RequestOptions) (*Pa
 	return query, nil
 }
 
+// NextDiag returns the next diag, or nil if there are no more diags.
+//
+// Deprecated: use Query[Diag].Next instead.
 func (query *PagedQuery) NextDiag() (*Diag, error) {
-	diag := &Diag{}
-	if len(query.PageResults.Data) == 0 {
-		return nil, nil
-	}
-	ok, err := query.NextEntity(diag)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get next diag: %s", err)
-	}
-	if !ok {
-		return nil, nil
-	}
-	return diag, nil
+	return nextEntityOf[Diag](query, "diag")
 }
 
-func (client *Client) DownloadDiags(clusterID string, fileName string) error {
-	return client.Download(
+func (client *Client) DownloadDiags(ctx context.Context, clusterID string, fileName string,
+	options *RequestOptions) error {
+	return client.Download(ctx,
 		fmt.Sprintf("clusters/%s/support/files/%s/content", clusterID, fileName),
 		fileName,
-		&RequestOptions{})
+		options)
 }
 
-func (client *Client) DownloadManyDiags(clusterID string, fileNames []string) error {
-	return client.DownloadMany(
+// DownloadManyDiags downloads several diags files. Options set the
+// concurrency and progress reporting, see DownloadMany.
+func (client *Client) DownloadManyDiags(ctx context.Context, clusterID string, fileNames []string,
+	options *RequestOptions) ([]DownloadResult, error) {
+	return client.DownloadMany(ctx,
 		fmt.Sprintf("clusters/%s/support/files/%%s/content", clusterID),
 		fileNames,
-		&RequestOptions{})
+		options)
 }
 
 func GetDiagsParams(topic string, topicId string) *QueryParams {
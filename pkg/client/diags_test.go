@@ -0,0 +1,78 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+// chdir changes the working directory for the rest of a test, since diags
+// are downloaded into the working directory
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestDownloadManyDiags(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	chdir(t, t.TempDir())
+	fileNames := []string{"diags-backend-0.tar.gz", "missing.tar.gz", "diags-backend-2.tar.gz"}
+	results, err := server.Client().DownloadManyDiags(context.Background(), fakehome.ActiveClusterID, fileNames,
+		&client.RequestOptions{Concurrency: 2})
+	if err == nil || !client.IsNotFound(err) {
+		t.Errorf("expected the error of the missing file, got %v", err)
+	}
+	if len(results) != len(fileNames) {
+		t.Fatalf("expected %d results, got %d", len(fileNames), len(results))
+	}
+	for i, result := range results {
+		if result.FileName != fileNames[i] {
+			t.Errorf("expected result %d to be of %s, got %s", i, fileNames[i], result.FileName)
+		}
+		if i == 1 {
+			if !client.IsNotFound(result.Err) {
+				t.Errorf("expected %s not to be found, got %v", result.FileName, result.Err)
+			}
+			continue
+		}
+		if result.Err != nil || result.Bytes != int64(len("diagnostics of backend-0\n")) {
+			t.Errorf("expected %s to be downloaded, got %+v", result.FileName, result)
+		}
+		if _, err := os.Stat(result.FileName); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDownloadManyDiagsCancelled(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	chdir(t, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := server.Client().DownloadManyDiags(ctx, fakehome.ActiveClusterID,
+		[]string{"diags-backend-0.tar.gz", "diags-backend-1.tar.gz"}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the downloads to be cancelled, got %v", err)
+	}
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("expected %s to be cancelled, got %v", result.FileName, result.Err)
+		}
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("expected no requests, got %v", server.Requests())
+	}
+}
@@ -0,0 +1,426 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// PartFileSuffix is appended to the name of a file while it is downloaded
+const PartFileSuffix = ".part"
+
+// validatorFileSuffix is appended to the name of a part file to name the file
+// holding the validator (ETag or Last-Modified) of the data it contains
+const validatorFileSuffix = ".validator"
+
+// ErrChecksumMismatch is returned when a downloaded file does not match the
+// checksum sent by the server
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// interruptedTransferError is returned when a download stopped before the
+// whole file was received, and can be resumed
+type interruptedTransferError struct {
+	err error
+}
+
+func (e *interruptedTransferError) Error() string {
+	return fmt.Sprintf("download interrupted: %s", e.err)
+}
+
+func (e *interruptedTransferError) Unwrap() error {
+	return e.err
+}
+
+// Download downloads a file into fileName. Data is first written into
+// fileName + PartFileSuffix, which is renamed to fileName only once the
+// download is complete and verified against the size and any checksum sent
+// by the server, so fileName never holds a truncated file. A part file left
+// over by an interrupted download is resumed using an HTTP Range request, if
+// the server sent a validator for it, so that the server sends the whole file
+// instead if it changed in the meantime.
+// Transfers interrupted midway are resumed according to the client's
+// RetryPolicy.
+func (client *Client) Download(ctx context.Context, url string, fileName string, options *RequestOptions) error {
+	_, err := client.download(ctx, url, fileName, options)
+	return err
+}
+
+// download downloads a file as described by Download, and returns its size
+func (client *Client) download(ctx context.Context, url string, fileName string, options *RequestOptions) (int64, error) {
+	if options == nil {
+		options = &RequestOptions{}
+	}
+	fullURL := client.getFullURL(url, options)
+	bodyBytes, err := options.encodeBody()
+	if err != nil {
+		return 0, err
+	}
+	size, err := client.downloadWithRetries(ctx, fullURL, bodyBytes, fileName, options.Progress)
+	if options.Progress != nil {
+		options.Progress.Done(fileName, err)
+	}
+	return size, err
+}
+
+// downloadWithRetries downloads fileName, resuming interrupted transfers
+func (client *Client) downloadWithRetries(ctx context.Context, fullURL string, bodyBytes []byte, fileName string,
+	progress ProgressReporter) (int64, error) {
+	partName := fileName + PartFileSuffix
+	for attempt := 1; ; attempt++ {
+		err := client.downloadPart(ctx, fullURL, bodyBytes, partName, progress)
+		if err == nil {
+			break
+		}
+		var interrupted *interruptedTransferError
+		if !errors.As(err, &interrupted) || attempt >= client.RetryPolicy.MaxAttempts || ctx.Err() != nil {
+			return 0, err
+		}
+		delay := client.RetryPolicy.backoff(attempt, nil)
+		logger.Warn().
+			Str("url", fullURL).
+			Str("file", fileName).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Err(err).
+			Msg("Download interrupted, resuming")
+		if err := sleepContext(ctx, delay); err != nil {
+			return 0, err
+		}
+	}
+	if err := os.Rename(partName, fileName); err != nil {
+		return 0, fmt.Errorf("failed to move downloaded file into place: %w", err)
+	}
+	os.Remove(partName + validatorFileSuffix)
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// downloadPart downloads a file into partName, resuming from its current size
+// if it exists. Progress, if not nil, is reported under the final file name.
+func (client *Client) downloadPart(ctx context.Context, fullURL string, bodyBytes []byte, partName string,
+	progress ProgressReporter) error {
+	var offset int64
+	// A part file is only resumed if it is known to hold the same version of
+	// the file as the server, otherwise it is downloaded again
+	validator := readValidator(partName)
+	if info, err := os.Stat(partName); err == nil && validator != "" {
+		offset = info.Size()
+	}
+	res, err := client.do(ctx, "GET", fullURL, bodyBytes, func(header http.Header) {
+		if offset > 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			header.Set("If-Range", validator)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		_, _, total, _ := parseContentRange(res.Header.Get("Content-Range"))
+		if total == offset {
+			logger.Debug().Str("file", partName).Msg("Partial download is already complete")
+			if err := verifyChecksums(partName, res.Header, false); err != nil {
+				os.Remove(partName)
+				return err
+			}
+			return nil
+		}
+		os.Remove(partName)
+		return &interruptedTransferError{errors.New("partial download does not match the remote file")}
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		logger.Error().
+			Str("method", "GET").
+			Str("url", res.Request.URL.String()).
+			Int("status", res.StatusCode).
+			Msg("Response")
+		return newAPIError("GET", res)
+	}
+	logger.Debug().
+		Str("method", "GET").
+		Str("url", res.Request.URL.String()).
+		Int("status", res.StatusCode).
+		Int64("offset", offset).
+		Msg("Response")
+
+	encoding := res.Header.Get("Content-Encoding")
+	compressed := encoding != "" && encoding != "identity"
+	flags := os.O_CREATE | os.O_WRONLY
+	expectedSize := int64(-1)
+	var reader io.Reader = res.Body
+	if res.StatusCode == http.StatusPartialContent {
+		start, _, total, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if !ok || start != offset || compressed {
+			os.Remove(partName)
+			return &interruptedTransferError{errors.New("server sent an unexpected range, restarting download")}
+		}
+		flags |= os.O_APPEND
+		expectedSize = total
+	} else {
+		// No range was requested, or the server ignored it and sends the
+		// whole file
+		flags |= os.O_TRUNC
+		offset = 0
+		switch encoding {
+		case "", "identity":
+			expectedSize = res.ContentLength
+		case "gzip":
+			gzipReader, err := gzip.NewReader(res.Body)
+			if err != nil {
+				return fmt.Errorf("failed to decompress download: %w", err)
+			}
+			defer gzipReader.Close()
+			reader = gzipReader
+		default:
+			return fmt.Errorf("unsupported content encoding: %s", encoding)
+		}
+	}
+
+	if compressed {
+		os.Remove(partName + validatorFileSuffix)
+	} else if err := writeValidator(partName, res.Header); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(partName, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open destination file: %w", err)
+	}
+	var writer io.Writer = file
+	if progress != nil {
+		fileName := strings.TrimSuffix(partName, PartFileSuffix)
+		progress.Start(fileName, offset, expectedSize)
+		writer = &progressWriter{writer: file, fileName: fileName, reporter: progress}
+	}
+	written, copyErr := io.Copy(writer, reader)
+	closeErr := file.Close()
+	if copyErr != nil {
+		if compressed {
+			// Decompressed data cannot be resumed with a range request
+			os.Remove(partName)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return &interruptedTransferError{copyErr}
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to write destination file: %w", closeErr)
+	}
+	if size := offset + written; expectedSize >= 0 && size != expectedSize {
+		if size > expectedSize {
+			os.Remove(partName)
+		}
+		return &interruptedTransferError{fmt.Errorf("expected %d bytes, got %d", expectedSize, size)}
+	}
+	if !compressed {
+		if err := verifyChecksums(partName, res.Header, res.StatusCode == http.StatusOK); err != nil {
+			os.Remove(partName)
+			return err
+		}
+	}
+	return nil
+}
+
+// readValidator returns the validator of the data in partName, or an empty
+// string if it is unknown
+func readValidator(partName string) string {
+	data, err := os.ReadFile(partName + validatorFileSuffix)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeValidator records the validator of a response written into partName.
+// Weak ETags cannot be used in If-Range, so Last-Modified is used instead.
+func writeValidator(partName string, header http.Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+	validatorName := partName + validatorFileSuffix
+	if validator == "" {
+		if err := os.Remove(validatorName); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove download validator: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(validatorName, []byte(validator), 0644); err != nil {
+		return fmt.Errorf("failed to write download validator: %w", err)
+	}
+	return nil
+}
+
+// parseContentRange parses a "bytes <start>-<end>/<total>" or
+// "bytes */<total>" Content-Range header. Unknown values are returned as -1.
+func parseContentRange(value string) (start int64, end int64, total int64, ok bool) {
+	start, end, total = -1, -1, -1
+	rangeSpec, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return start, end, total, false
+	}
+	byteRange, totalText, found := strings.Cut(rangeSpec, "/")
+	if !found {
+		return start, end, total, false
+	}
+	if totalText != "*" {
+		if parsed, err := strconv.ParseInt(totalText, 10, 64); err == nil {
+			total = parsed
+		}
+	}
+	if byteRange == "*" {
+		return start, end, total, true
+	}
+	startText, endText, found := strings.Cut(byteRange, "-")
+	if !found {
+		return start, end, total, false
+	}
+	var err1, err2 error
+	start, err1 = strconv.ParseInt(startText, 10, 64)
+	end, err2 = strconv.ParseInt(endText, 10, 64)
+	return start, end, total, err1 == nil && err2 == nil
+}
+
+// verifyChecksums verifies a downloaded file against the checksum headers
+// sent by the server: "Digest" (RFC 3230, SHA-256 or MD5) and
+// "X-Checksum-Sha256" describe the whole file, while "Content-MD5" only
+// describes the response body and is verified for complete responses only.
+func verifyChecksums(fileName string, header http.Header, completeResponse bool) error {
+	type checksum struct {
+		name     string
+		expected []byte
+		hash     hash.Hash
+	}
+	var checksums []checksum
+	for _, digest := range strings.Split(header.Get("Digest"), ",") {
+		algorithm, value, found := strings.Cut(strings.TrimSpace(digest), "=")
+		if !found {
+			continue
+		}
+		expected, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(algorithm) {
+		case "sha-256":
+			checksums = append(checksums, checksum{"SHA-256", expected, sha256.New()})
+		case "md5":
+			checksums = append(checksums, checksum{"MD5", expected, md5.New()})
+		}
+	}
+	if value := header.Get("X-Checksum-Sha256"); value != "" {
+		if expected, err := hex.DecodeString(value); err == nil {
+			checksums = append(checksums, checksum{"SHA-256", expected, sha256.New()})
+		}
+	}
+	if value := header.Get("Content-MD5"); value != "" && completeResponse {
+		if expected, err := base64.StdEncoding.DecodeString(value); err == nil {
+			checksums = append(checksums, checksum{"MD5", expected, md5.New()})
+		}
+	}
+	if len(checksums) == 0 {
+		return nil
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writers := make([]io.Writer, len(checksums))
+	for i := range checksums {
+		writers[i] = checksums[i].hash
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return fmt.Errorf("failed to verify downloaded file: %w", err)
+	}
+	for _, checksum := range checksums {
+		if actual := checksum.hash.Sum(nil); string(actual) != string(checksum.expected) {
+			return fmt.Errorf("%w: %s of %s is %x, expected %x",
+				ErrChecksumMismatch, checksum.name, fileName, actual, checksum.expected)
+		}
+	}
+	return nil
+}
+
+// DefaultDownloadConcurrency is the number of files DownloadMany downloads at
+// once, unless set otherwise by RequestOptions.Concurrency
+const DefaultDownloadConcurrency = 16
+
+// DownloadResult is the outcome of downloading a single file with
+// DownloadMany
+type DownloadResult struct {
+	FileName string
+	// Bytes is the size of the downloaded file
+	Bytes    int64
+	Duration time.Duration
+	// Err is nil if the file was downloaded successfully
+	Err error
+}
+
+// DownloadMany downloads several files concurrently, and returns the result of
+// every file in the order of fileNames, along with the errors of all failed
+// files joined together. No new downloads are started once ctx is cancelled,
+// and downloads in progress are aborted.
+func (client *Client) DownloadMany(ctx context.Context, urlTemplate string, fileNames []string,
+	options *RequestOptions) ([]DownloadResult, error) {
+	downloadOptions := RequestOptions{}
+	if options != nil {
+		downloadOptions = *options
+	}
+	concurrency := DefaultDownloadConcurrency
+	if downloadOptions.Concurrency > 0 {
+		concurrency = downloadOptions.Concurrency
+	}
+	results := make([]DownloadResult, len(fileNames))
+	sem := semaphore.NewWeighted(int64(concurrency))
+	wg := sync.WaitGroup{}
+	for i, file := range fileNames {
+		results[i].FileName = file
+		if err := sem.Acquire(ctx, 1); err != nil {
+			results[i].Err = err
+			continue
+		}
+		wg.Add(1)
+		go func(result *DownloadResult) {
+			defer func() {
+				sem.Release(1)
+				wg.Done()
+			}()
+			// Downloads fill in their options, so each gets its own copy
+			fileOptions := downloadOptions
+			start := time.Now()
+			result.Bytes, result.Err = client.download(ctx, fmt.Sprintf(urlTemplate, result.FileName),
+				result.FileName, &fileOptions)
+			result.Duration = time.Since(start)
+		}(&results[i])
+	}
+	wg.Wait()
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.FileName, result.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
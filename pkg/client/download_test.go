@@ -0,0 +1,278 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var downloadContent = bytes.Repeat([]byte("0123456789abcdef"), 4096)
+
+// downloadETag is the validator of downloadContent
+const downloadETag = `"v1"`
+
+// newFileServer returns a server serving downloadContent with range support.
+// handle, if not nil, is called first and may handle requests itself by
+// returning true.
+func newFileServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request, n int32) bool) (
+	*httptest.Server, *[]string) {
+	t.Helper()
+	var requests atomic.Int32
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", downloadETag)
+		if handle != nil && handle(w, r, n) {
+			return
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(downloadContent))
+	}))
+	t.Cleanup(server.Close)
+	return server, &ranges
+}
+
+func downloadFile(t *testing.T, serverURL string) (string, error) {
+	t.Helper()
+	client := newTestClient(serverURL)
+	fileName := filepath.Join(t.TempDir(), "file")
+	return fileName, client.Download(context.Background(), "file", fileName, nil)
+}
+
+func assertDownloaded(t *testing.T, fileName string) {
+	t.Helper()
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, downloadContent) {
+		t.Errorf("downloaded %d bytes not matching the %d bytes served", len(content), len(downloadContent))
+	}
+	if _, err := os.Stat(fileName + PartFileSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected the part file to be removed")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server, _ := newFileServer(t, nil)
+	fileName, err := downloadFile(t, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDownloaded(t, fileName)
+}
+
+func TestDownloadResumesInterruptedTransfer(t *testing.T) {
+	server, ranges := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int32) bool {
+		if n > 1 {
+			return false
+		}
+		w.Header().Set("Content-Length", "65536")
+		w.WriteHeader(http.StatusOK)
+		w.Write(downloadContent[:1000])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	})
+	fileName, err := downloadFile(t, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDownloaded(t, fileName)
+	if len(*ranges) != 2 || (*ranges)[1] != "bytes=1000-" {
+		t.Errorf("expected the download to be resumed from byte 1000, got ranges %q", *ranges)
+	}
+}
+
+func TestDownloadResumesPartFile(t *testing.T) {
+	server, ranges := newFileServer(t, nil)
+	client := newTestClient(server.URL)
+	fileName := filepath.Join(t.TempDir(), "file")
+	writePartFile(t, fileName, downloadContent[:5000], downloadETag)
+	if err := client.Download(context.Background(), "file", fileName, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertDownloaded(t, fileName)
+	if (*ranges)[0] != "bytes=5000-" {
+		t.Errorf("expected the part file to be resumed, got ranges %q", *ranges)
+	}
+
+	// A complete part file is only renamed
+	writePartFile(t, fileName, downloadContent, downloadETag)
+	if err := client.Download(context.Background(), "file", fileName, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertDownloaded(t, fileName)
+}
+
+// writePartFile leaves a part file of fileName behind, as an interrupted
+// download would, holding the given content of the given version
+func writePartFile(t *testing.T, fileName string, content []byte, validator string) {
+	t.Helper()
+	partName := fileName + PartFileSuffix
+	if err := os.WriteFile(partName, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if validator != "" {
+		if err := os.WriteFile(partName+validatorFileSuffix, []byte(validator), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDownloadRestartsChangedPartFile(t *testing.T) {
+	server, ranges := newFileServer(t, nil)
+	client := newTestClient(server.URL)
+	fileName := filepath.Join(t.TempDir(), "file")
+	// The part file was downloaded from another version of the file, or from
+	// an unknown version
+	for _, validator := range []string{`"v0"`, ""} {
+		*ranges = nil
+		writePartFile(t, fileName, bytes.Repeat([]byte("x"), 5000), validator)
+		if err := client.Download(context.Background(), "file", fileName, nil); err != nil {
+			t.Fatal(err)
+		}
+		assertDownloaded(t, fileName)
+		if len(*ranges) != 1 || (validator == "") != ((*ranges)[0] == "") {
+			t.Errorf("%q: unexpected ranges %q", validator, *ranges)
+		}
+		if _, err := os.Stat(fileName + PartFileSuffix + validatorFileSuffix); !os.IsNotExist(err) {
+			t.Errorf("expected the validator file to be removed")
+		}
+	}
+}
+
+func TestDownloadVerifiesCompletePartFile(t *testing.T) {
+	server, _ := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int32) bool {
+		w.Header().Set("X-Checksum-Sha256", strings.Repeat("00", sha256.Size))
+		return false
+	})
+	client := newTestClient(server.URL)
+	fileName := filepath.Join(t.TempDir(), "file")
+	writePartFile(t, fileName, downloadContent, downloadETag)
+	err := client.Download(context.Background(), "file", fileName, nil)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	for _, name := range []string{fileName, fileName + PartFileSuffix} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to exist", name)
+		}
+	}
+}
+
+func TestDownloadChecksums(t *testing.T) {
+	sum := sha256.Sum256(downloadContent)
+	for checksum, valid := range map[string]bool{
+		hex.EncodeToString(sum[:]):     true,
+		strings.Repeat("00", len(sum)): false,
+	} {
+		server, _ := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int32) bool {
+			w.Header().Set("X-Checksum-Sha256", checksum)
+			return false
+		})
+		fileName, err := downloadFile(t, server.URL)
+		if valid {
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertDownloaded(t, fileName)
+			continue
+		}
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("expected a checksum mismatch, got %v", err)
+		}
+		for _, name := range []string{fileName, fileName + PartFileSuffix} {
+			if _, err := os.Stat(name); !os.IsNotExist(err) {
+				t.Errorf("expected %s not to exist", name)
+			}
+		}
+	}
+}
+
+func TestDownloadTruncated(t *testing.T) {
+	server, _ := newFileServer(t, func(w http.ResponseWriter, r *http.Request, n int32) bool {
+		w.Header().Set("Content-Length", "65536")
+		w.Write(downloadContent[:1000])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	})
+	fileName, err := downloadFile(t, server.URL)
+	var interrupted *interruptedTransferError
+	if !errors.As(err, &interrupted) {
+		t.Errorf("expected an interrupted download, got %v", err)
+	}
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Errorf("expected no truncated file to be left behind")
+	}
+}
+
+func TestResponseTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	client := newTestClient(server.URL)
+	client.RetryPolicy.MaxAttempts = 1
+	client.Timeout = 50 * time.Millisecond
+	err := client.Get(context.Background(), "status", nil, nil)
+	if !errors.Is(err, ErrResponseTimeout) {
+		t.Errorf("expected a response timeout, got %v", err)
+	}
+}
+
+func TestTimeoutExcludesBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "20")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 2; i++ {
+			w.Write([]byte("0123456789"))
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	client := newTestClient(server.URL)
+	client.Timeout = 50 * time.Millisecond
+	fileName := filepath.Join(t.TempDir(), "file")
+	if err := client.Download(context.Background(), "file", fileName, nil); err != nil {
+		t.Fatalf("expected a slow body not to time out, got %v", err)
+	}
+	if info, err := os.Stat(fileName); err != nil || info.Size() != 20 {
+		t.Errorf("expected 20 bytes to be downloaded, got %v, %v", info, err)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	for value, expected := range map[string][4]int64{
+		"bytes 0-99/1000": {0, 99, 1000, 1},
+		"bytes 100-199/*": {100, 199, -1, 1},
+		"bytes */1000":    {-1, -1, 1000, 1},
+		"items 0-99/1000": {-1, -1, -1, 0},
+		"bytes 0-99":      {-1, -1, -1, 0},
+		"":                {-1, -1, -1, 0},
+		"bytes 5-5/6":     {5, 5, 6, 1},
+		"bytes 10/1000":   {-1, -1, 1000, 0},
+	} {
+		start, end, total, ok := parseContentRange(value)
+		okValue := int64(0)
+		if ok {
+			okValue = 1
+		}
+		if actual := [4]int64{start, end, total, okValue}; actual != expected {
+			t.Errorf("%q: expected %v, got %v", value, expected, actual)
+		}
+	}
+}
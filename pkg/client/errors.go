@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodySize limits how much of an error response body is read
+const maxErrorBodySize = 1 << 20
+
+// ErrorObject is a single JSON:API error object, as returned by the server in
+// the "errors" array of an error response
+type ErrorObject struct {
+	ID     string                 `json:"id,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Source map[string]interface{} `json:"source,omitempty"`
+}
+
+// APIError is returned when the server responds with a non-successful HTTP
+// status. Use errors.As to retrieve it from errors returned by the client.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	RequestID  string
+	Errors     []ErrorObject
+	// Body holds the raw response body when it is not a JSON:API error document
+	Body []byte
+}
+
+func (apiError *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s returned HTTP %d", apiError.Method, apiError.URL, apiError.StatusCode)
+	var details []string
+	for _, errorObject := range apiError.Errors {
+		switch {
+		case errorObject.Title != "" && errorObject.Detail != "":
+			details = append(details, fmt.Sprintf("%s: %s", errorObject.Title, errorObject.Detail))
+		case errorObject.Detail != "":
+			details = append(details, errorObject.Detail)
+		case errorObject.Title != "":
+			details = append(details, errorObject.Title)
+		}
+	}
+	if len(details) != 0 {
+		msg = fmt.Sprintf("%s: %s", msg, strings.Join(details, "; "))
+	}
+	if apiError.RequestID != "" {
+		msg = fmt.Sprintf("%s (request ID %s)", msg, apiError.RequestID)
+	}
+	return msg
+}
+
+// newAPIError builds an APIError from a non-successful response, consuming
+// (part of) its body
+func newAPIError(method string, res *http.Response) *APIError {
+	apiError := &APIError{
+		Method:     method,
+		URL:        res.Request.URL.String(),
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("X-Request-ID"),
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	if err != nil || len(body) == 0 {
+		return apiError
+	}
+	document := struct {
+		Errors []ErrorObject `json:"errors"`
+	}{}
+	if err := json.Unmarshal(body, &document); err == nil && len(document.Errors) != 0 {
+		apiError.Errors = document.Errors
+	} else {
+		apiError.Body = body
+	}
+	return apiError
+}
+
+// HasStatus reports whether err is (or wraps) an APIError with the given
+// HTTP status code
+func HasStatus(err error, statusCode int) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == statusCode
+}
+
+// IsNotFound reports whether err was caused by an HTTP 404 response
+func IsNotFound(err error) bool {
+	return HasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err was caused by an HTTP 401 response,
+// usually due to a missing or invalid API key
+func IsUnauthorized(err error) bool {
+	return HasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err was caused by an HTTP 403 response
+func IsForbidden(err error) bool {
+	return HasStatus(err, http.StatusForbidden)
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -30,28 +31,57 @@ func (event *Event) ComputeProcessingTime() float64 {
 }
 
 // GetCluster returns a single event
-func (client *Client) GetEvent(clusterID string, eventID string) (*Event, error) {
+func (client *Client) GetEvent(ctx context.Context, clusterID string, eventID string) (*Event, error) {
 	logger.Info().Str("clusterID", clusterID).Str("eventID", eventID).Msg("Fetching event")
 	event := &Event{}
-	err := client.Get(fmt.Sprintf("events/%s", eventID), event, &RequestOptions{Prefix: "api"})
+	err := client.Get(ctx, fmt.Sprintf("events/%s", eventID), event, &RequestOptions{Prefix: "api"})
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch event %s: %s", eventID, err)
+		return nil, fmt.Errorf("could not fetch event %s: %w", eventID, err)
 	}
 	return event, nil
 }
 
+// SortOrder is the order in which events are returned. The server has no
+// parameter for it and always returns events newest first, so SortAscending is
+// implemented by the client, see QueryEvents.
+type SortOrder string
+
+const (
+	// SortDescending returns the newest events first. It is the default.
+	SortDescending SortOrder = "desc"
+	// SortAscending returns the oldest events first
+	SortAscending SortOrder = "asc"
+)
+
+// MaxAscendingEvents is the maximum number of events returned by a query in
+// SortAscending, which are all held in memory
+const MaxAscendingEvents = 10000
+
 type EventQueryOptions struct {
 	WithInternalEvents bool
 	SortByIngestTime   bool
-	IncludeTypes       []string
-	ExcludeTypes       []string
-	NodeIDs            []int
-	MinSeverity        string
-	StartTime          time.Time
-	EndTime            time.Time
-	Limit              int
-	Wide               bool
-	//Params             string
+	// SortOrder is SortDescending if empty
+	SortOrder    SortOrder
+	IncludeTypes []string
+	ExcludeTypes []string
+	NodeIDs      []int
+	MinSeverity  string
+	StartTime    time.Time
+	EndTime      time.Time
+	// Limit is the page size of the query. With SortAscending, it is also the
+	// maximum number of events returned, the newest ones; zero means
+	// MaxAscendingEvents.
+	Limit    int
+	Wide     bool
+	Prefetch int
+	// Params filters events by their parameters. Events must match all
+	// filters. The server has no parameter filter, so filters are evaluated
+	// by the client, and the server returns all events otherwise matching the
+	// query.
+	Params []ParamFilter
+	// OnTruncated, if set, is called when a query in SortAscending returns
+	// only the newest of the events matching it, with the number returned
+	OnTruncated func(returned int)
 }
 
 func (options *EventQueryOptions) ToQueryParams() (*QueryParams, error) {
@@ -90,38 +120,152 @@ func (options *EventQueryOptions) ToQueryParams() (*QueryParams, error) {
 	//if options.Limit!=0 {
 	//	params.Set("page_size", limit)
 	//}
-	//if options.Params != "" {
-	//	params.Set("params" ,options.Params)
-	//}
 	return params, nil
 }
 
-func (client *Client) QueryEvents(clusterID string, options *EventQueryOptions) (*PagedQuery, error) {
-	var params *QueryParams
-	if options != nil {
-		var err error
-		params, err = options.ToQueryParams()
+// QueryEvents starts a paged query of the events of a cluster, in
+// options.SortOrder. The server returns events newest first, so with
+// SortAscending, the newest options.Limit events (at most MaxAscendingEvents)
+// are fetched upfront and returned in reverse, and options.OnTruncated is
+// called if older events were left out. Events are filtered by their
+// parameters locally.
+func (client *Client) QueryEvents(ctx context.Context, clusterID string, options *EventQueryOptions) (*Query[Event], error) {
+	if options == nil {
+		options = &EventQueryOptions{}
+	}
+	serverOptions, local, err := splitEventQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	var query *Query[Event]
+	if local.sortAscending {
+		query, err = client.queryEventsAscending(ctx, clusterID, serverOptions, local.filter)
+		local.filter = nil
+	} else {
+		var requestOptions *RequestOptions
+		requestOptions, err = eventsRequestOptions(serverOptions)
 		if err != nil {
 			return nil, err
 		}
+		if local.filter != nil && requestOptions.PageSize <= 0 {
+			// Most events fetched may be filtered out
+			requestOptions.PageSize = maxPageSize
+		}
+		query, err = QueryEntitiesOf[Event](ctx, client, fmt.Sprintf("%s/events/list", clusterID), requestOptions)
 	}
-	query, err := client.QueryEntities(
-		fmt.Sprintf("%s/events/list", clusterID),
-		&RequestOptions{Prefix: "api", NoMetadata: true, Params: params, PageSize: options.Limit})
 	if err != nil {
 		return nil, err
 	}
+	query.filter = local.filter
 	return query, nil
 }
 
-func (query *PagedQuery) NextEvent() (*Event, error) {
-	event := &Event{}
-	ok, err := query.NextEntity(event)
+// localEventQuery is the part of an event query that the server does not
+// support, and that is left to the client
+type localEventQuery struct {
+	sortAscending bool
+	// filter is nil if all events match
+	filter func(event *Event) bool
+}
+
+// splitEventQuery returns the options of an event query supported by the
+// server, and what is left to the client
+func splitEventQuery(options *EventQueryOptions) (*EventQueryOptions, localEventQuery, error) {
+	serverOptions := *options
+	local := localEventQuery{}
+	switch options.SortOrder {
+	case "", SortDescending:
+	case SortAscending:
+		local.sortAscending = true
+		serverOptions.SortOrder = SortDescending
+	default:
+		return nil, local, fmt.Errorf("invalid sort order: %s", options.SortOrder)
+	}
+	if len(options.Params) != 0 {
+		filters := options.Params
+		local.filter = func(event *Event) bool {
+			return matchParams(event, filters)
+		}
+		serverOptions.Params = nil
+	}
+	return &serverOptions, local, nil
+}
+
+// queryEventsAscending fetches the newest options.Limit events matching filter
+// (MaxAscendingEvents if options.Limit is zero), and returns a query over them
+// in reverse. options must be sorted in descending order, and filter may be
+// nil.
+func (client *Client) queryEventsAscending(ctx context.Context, clusterID string,
+	options *EventQueryOptions, filter func(event *Event) bool) (*Query[Event], error) {
+	requestOptions, err := eventsRequestOptions(options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get next event: %s", err)
+		return nil, err
 	}
-	if !ok {
-		return nil, nil
+	maxEvents := options.Limit
+	if maxEvents <= 0 || maxEvents > MaxAscendingEvents {
+		maxEvents = MaxAscendingEvents
 	}
-	return event, nil
+	// All events are needed before the first can be returned, so fetch them
+	// in as few requests as possible, along with one more event telling
+	// whether older events are left out
+	requestOptions.PageSize = maxPageSize
+	if maxEvents < maxPageSize {
+		requestOptions.PageSize = maxEvents + 1
+	}
+	url := fmt.Sprintf("%s/events/list", clusterID)
+	query, err := QueryEntitiesOf[json.RawMessage](ctx, client, url, requestOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer query.Close()
+	var events []json.RawMessage
+	truncated := false
+	for query.Next() {
+		if filter != nil {
+			event := &Event{}
+			if err := json.Unmarshal(*query.Value(), event); err != nil {
+				return nil, fmt.Errorf("failed to parse event: %w", err)
+			}
+			if !filter(event) {
+				continue
+			}
+		}
+		if len(events) == maxEvents {
+			truncated = true
+			break
+		}
+		events = append(events, *query.Value())
+	}
+	if err := query.Err(); err != nil {
+		return nil, err
+	}
+	if truncated && options.OnTruncated != nil {
+		options.OnTruncated(len(events))
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	logger.Debug().Int("events", len(events)).Msg("Sorted events oldest first locally")
+	return NewQuery[Event](newPreloadedQuery(ctx, client, url, events)), nil
+}
+
+func eventsRequestOptions(options *EventQueryOptions) (*RequestOptions, error) {
+	requestOptions := &RequestOptions{Prefix: "api", NoMetadata: true}
+	if options != nil {
+		params, err := options.ToQueryParams()
+		if err != nil {
+			return nil, err
+		}
+		requestOptions.Params = params
+		requestOptions.PageSize = options.Limit
+		requestOptions.Prefetch = options.Prefetch
+	}
+	return requestOptions, nil
+}
+
+// NextEvent returns the next event, or nil if there are no more events.
+//
+// Deprecated: use Query[Event].Next instead.
+func (query *PagedQuery) NextEvent() (*Event, error) {
+	return nextEntityOf[Event](query, "event")
 }
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ParamOperator compares the value of an event parameter, see ParamFilter
+type ParamOperator string
+
+const (
+	ParamEquals       ParamOperator = "="
+	ParamNotEquals    ParamOperator = "!="
+	ParamMatches      ParamOperator = "~"
+	ParamDoesNotMatch ParamOperator = "!~"
+)
+
+// ParamFilter matches events by the value of one of their parameters. Values
+// are compared as text: strings as they are, and other JSON values as JSON,
+// e.g. 3 or true. Events without the parameter match only the negated
+// operators.
+type ParamFilter struct {
+	Name     string
+	Operator ParamOperator
+	Value    string
+	pattern  *regexp.Regexp
+}
+
+// ParseParamFilter parses a filter such as "hostname=backend-1",
+// "hostname!=backend-1", "hostname~^backend-[0-3]$" or "hostname!~^client"
+func ParseParamFilter(text string) (ParamFilter, error) {
+	i := strings.IndexAny(text, "!=~")
+	if i <= 0 {
+		return ParamFilter{}, fmt.Errorf("invalid parameter filter \"%s\", expected name=value, "+
+			"name!=value, name~regex or name!~regex", text)
+	}
+	filter := ParamFilter{Name: text[:i]}
+	rest := text[i:]
+	for _, operator := range []ParamOperator{ParamNotEquals, ParamDoesNotMatch, ParamEquals, ParamMatches} {
+		if strings.HasPrefix(rest, string(operator)) {
+			filter.Operator = operator
+			filter.Value = strings.TrimPrefix(rest, string(operator))
+			break
+		}
+	}
+	if filter.Operator == "" {
+		return ParamFilter{}, fmt.Errorf("invalid parameter filter \"%s\", expected name=value, "+
+			"name!=value, name~regex or name!~regex", text)
+	}
+	if filter.Operator == ParamMatches || filter.Operator == ParamDoesNotMatch {
+		pattern, err := regexp.Compile(filter.Value)
+		if err != nil {
+			return ParamFilter{}, fmt.Errorf("invalid parameter filter \"%s\": %w", text, err)
+		}
+		filter.pattern = pattern
+	}
+	return filter, nil
+}
+
+// String returns the filter in the format parsed by ParseParamFilter
+func (filter *ParamFilter) String() string {
+	return filter.Name + string(filter.Operator) + filter.Value
+}
+
+// Matches reports whether the parameters of an event match the filter
+func (filter *ParamFilter) Matches(event *Event) bool {
+	value, found := eventParam(event, filter.Name)
+	switch filter.Operator {
+	case ParamEquals:
+		return found && value == filter.Value
+	case ParamNotEquals:
+		return !found || value != filter.Value
+	case ParamMatches:
+		return found && filter.regexp().MatchString(value)
+	case ParamDoesNotMatch:
+		return !found || !filter.regexp().MatchString(value)
+	}
+	return false
+}
+
+// regexp returns the compiled pattern of the filter, compiling it if the
+// filter was not created by ParseParamFilter
+func (filter *ParamFilter) regexp() *regexp.Regexp {
+	if filter.pattern == nil {
+		pattern, err := regexp.Compile(filter.Value)
+		if err != nil {
+			// Matches nothing
+			pattern = regexp.MustCompile(`[^\s\S]`)
+		}
+		filter.pattern = pattern
+	}
+	return filter.pattern
+}
+
+// eventParam returns the value of an event parameter as text
+func eventParam(event *Event, name string) (string, bool) {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(event.Params, &params); err != nil {
+		return "", false
+	}
+	raw, found := params[name]
+	if !found {
+		return "", false
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, true
+	}
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, raw); err != nil {
+		return string(raw), true
+	}
+	return compact.String(), true
+}
+
+// matchParams returns whether an event matches all filters
+func matchParams(event *Event, filters []ParamFilter) bool {
+	for i := range filters {
+		if !filters[i].Matches(event) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package client_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestParseParamFilter(t *testing.T) {
+	for text, expected := range map[string]client.ParamFilter{
+		"hostname=backend-1":   {Name: "hostname", Operator: client.ParamEquals, Value: "backend-1"},
+		"hostname!=backend-1":  {Name: "hostname", Operator: client.ParamNotEquals, Value: "backend-1"},
+		"hostname~^backend-1$": {Name: "hostname", Operator: client.ParamMatches, Value: "^backend-1$"},
+		"hostname!~^client":    {Name: "hostname", Operator: client.ParamDoesNotMatch, Value: "^client"},
+		"url=a=b":              {Name: "url", Operator: client.ParamEquals, Value: "a=b"},
+		"empty=":               {Name: "empty", Operator: client.ParamEquals, Value: ""},
+	} {
+		filter, err := client.ParseParamFilter(text)
+		if err != nil {
+			t.Errorf("%s: %s", text, err)
+			continue
+		}
+		if filter.Name != expected.Name || filter.Operator != expected.Operator || filter.Value != expected.Value {
+			t.Errorf("%s: expected %+v, got %+v", text, expected, filter)
+		}
+		if filter.String() != text {
+			t.Errorf("%s: expected the filter to be formatted back, got %s", text, filter.String())
+		}
+	}
+	for _, text := range []string{"hostname", "=value", "hostname!value", "hostname~("} {
+		if _, err := client.ParseParamFilter(text); err == nil {
+			t.Errorf("%s: expected an error", text)
+		}
+	}
+}
+
+func TestParamFilterMatches(t *testing.T) {
+	event := &client.Event{Params: json.RawMessage(`{"hostname":"backend-1","drives":3,"ok":true,"ids":[1, 2]}`)}
+	for text, expected := range map[string]bool{
+		"hostname=backend-1":    true,
+		"hostname=backend-2":    false,
+		"hostname!=backend-2":   true,
+		"hostname~^backend-\\d": true,
+		"hostname!~backend":     false,
+		"drives=3":              true,
+		"ok=true":               true,
+		"ids=[1,2]":             true,
+		"missing=x":             false,
+		"missing!=x":            true,
+		"missing~.":             false,
+		"missing!~.":            true,
+	} {
+		filter, err := client.ParseParamFilter(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := filter.Matches(event); actual != expected {
+			t.Errorf("%s: expected %t, got %t", text, expected, actual)
+		}
+	}
+	// Filters not created by ParseParamFilter still work
+	filter := client.ParamFilter{Name: "hostname", Operator: client.ParamMatches, Value: "1$"}
+	if !filter.Matches(event) {
+		t.Errorf("expected %s to match", filter.String())
+	}
+	if (&client.ParamFilter{Name: "hostname", Operator: client.ParamMatches, Value: "("}).Matches(event) {
+		t.Errorf("expected an invalid pattern to match nothing")
+	}
+}
+
+func TestQueryEventsFiltersParams(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	filters := make([]client.ParamFilter, 2)
+	var err error
+	if filters[0], err = client.ParseParamFilter("hostname=backend-1"); err != nil {
+		t.Fatal(err)
+	}
+	if filters[1], err = client.ParseParamFilter("driveId!=1"); err != nil {
+		t.Fatal(err)
+	}
+	// Filtering happens after the server returns events, so the limit
+	// applies to the matching events
+	ids := queryEventIDs(t, api, &client.EventQueryOptions{Params: filters, Limit: 3}, 3)
+	if fmt.Sprint(ids) != "[1117 1109 1101]" {
+		t.Errorf("expected the 3 newest matching events, got %q", ids)
+	}
+	// The limit is still the page size
+	if requests := server.Requests(); !strings.Contains(requests[0], "page_size=3&") {
+		t.Errorf("expected pages of 3 events, got %s", requests[0])
+	}
+	ids = queryEventIDs(t, api, &client.EventQueryOptions{Params: filters, SortOrder: client.SortAscending, Limit: 3}, 0)
+	if fmt.Sprint(ids) != "[1101 1109 1117]" {
+		t.Errorf("expected the 3 newest matching events oldest first, got %q", ids)
+	}
+	for _, request := range server.Requests() {
+		if strings.Contains(request, "param") {
+			t.Errorf("expected the filters not to be sent to the server, got %s", request)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultFollowInterval is how often FollowEvents polls for new events
+	DefaultFollowInterval = 5 * time.Second
+	// DefaultFollowMaxBackoff caps the delay between polls of FollowEvents
+	// after consecutive errors
+	DefaultFollowMaxBackoff = time.Minute
+)
+
+// FollowOptions control how FollowEvents polls for new events
+type FollowOptions struct {
+	// Interval is the delay between polls, DefaultFollowInterval if zero
+	Interval time.Duration
+	// MaxBackoff caps the delay between polls after consecutive errors,
+	// which doubles with each error, DefaultFollowMaxBackoff if zero
+	MaxBackoff time.Duration
+	// OnError is called when a poll fails, with the delay until the next poll
+	OnError func(err error, retryIn time.Duration)
+}
+
+// eventFollower tracks the events already seen by FollowEvents
+type eventFollower struct {
+	client    *Client
+	clusterID string
+	options   EventQueryOptions
+	// watermark is the latest ingest time of the events seen so far. Events
+	// are queried from the watermark on, so seen holds the events ingested
+	// at the watermark, at the one-second precision of queries.
+	watermark time.Time
+	seen      map[string]time.Time
+	// filter, if set, skips events the server can't filter out
+	filter func(event *Event) bool
+}
+
+// FollowEvents calls f for each event of a cluster ingested from
+// options.StartTime on, or from now on if it is zero, in the order of their
+// ingest time. It polls the server until ctx is done, which it then returns
+// the error of, or until f returns an error, which it returns. Failed polls
+// are retried with exponential backoff. options.EndTime and options.SortOrder
+// are ignored, and options.Limit is the page size of each poll.
+func (client *Client) FollowEvents(ctx context.Context, clusterID string, options *EventQueryOptions,
+	followOptions *FollowOptions, f func(event *Event) error) error {
+	if options == nil {
+		options = &EventQueryOptions{}
+	}
+	followOrder := *options
+	followOrder.SortOrder = SortDescending
+	serverOptions, local, err := splitEventQuery(&followOrder)
+	if err != nil {
+		return err
+	}
+	follower := &eventFollower{client: client, clusterID: clusterID, options: *serverOptions,
+		filter: local.filter, seen: make(map[string]time.Time)}
+	follower.options.SortByIngestTime = true
+	follower.options.EndTime = time.Time{}
+	follower.options.Prefetch = 0
+	follower.watermark = follower.options.StartTime
+	if followOptions == nil {
+		followOptions = &FollowOptions{}
+	}
+	interval, maxBackoff := followOptions.Interval, followOptions.MaxBackoff
+	if interval <= 0 {
+		interval = DefaultFollowInterval
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultFollowMaxBackoff
+	}
+	initialized := !follower.watermark.IsZero()
+	failures := 0
+	for {
+		var events []*Event
+		var err error
+		if initialized {
+			events, err = follower.poll(ctx)
+		} else {
+			err = follower.init(ctx)
+			initialized = err == nil
+		}
+		delay := interval
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failures++
+			delay = interval << failures
+			if delay <= 0 || delay > maxBackoff {
+				delay = maxBackoff
+			}
+			logger.Debug().Err(err).Dur("retry_in", delay).Msg("Failed to poll for events")
+			if followOptions.OnError != nil {
+				followOptions.OnError(err, delay)
+			}
+		} else {
+			failures = 0
+		}
+		for _, event := range events {
+			if err := f(event); err != nil {
+				return err
+			}
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// init sets the watermark to the ingest time of the latest event, so that
+// only events ingested after it are followed
+func (follower *eventFollower) init(ctx context.Context) error {
+	query, err := follower.query(ctx)
+	if err != nil {
+		return err
+	}
+	defer query.Close()
+	for query.Next() {
+		event := query.Value()
+		if follower.watermark.IsZero() {
+			follower.watermark = event.IngestTime
+		} else if event.IngestTime.Before(follower.watermark.Truncate(time.Second)) {
+			return nil
+		}
+		follower.seen[event.CloudID] = event.IngestTime
+	}
+	if err := query.Err(); err != nil {
+		return err
+	}
+	if follower.watermark.IsZero() {
+		// No events yet
+		follower.watermark = time.Now()
+	}
+	return nil
+}
+
+// poll returns the events ingested since the last poll, oldest first
+func (follower *eventFollower) poll(ctx context.Context) ([]*Event, error) {
+	query, err := follower.query(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer query.Close()
+	var events []*Event
+	for query.Next() {
+		event := query.Value()
+		if _, seen := follower.seen[event.CloudID]; !seen {
+			events = append(events, event)
+		}
+	}
+	if err := query.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].IngestTime.Before(events[j].IngestTime)
+	})
+	matching := events[:0]
+	for _, event := range events {
+		follower.seen[event.CloudID] = event.IngestTime
+		if event.IngestTime.After(follower.watermark) {
+			follower.watermark = event.IngestTime
+		}
+		if follower.filter == nil || follower.filter(event) {
+			matching = append(matching, event)
+		}
+	}
+	// Forget events that are too old to be returned by the next poll
+	for cloudID, ingestTime := range follower.seen {
+		if ingestTime.Before(follower.watermark.Truncate(time.Second)) {
+			delete(follower.seen, cloudID)
+		}
+	}
+	return matching, nil
+}
+
+func (follower *eventFollower) query(ctx context.Context) (*Query[Event], error) {
+	options := follower.options
+	options.StartTime = follower.watermark
+	requestOptions, err := eventsRequestOptions(&options)
+	if err != nil {
+		return nil, err
+	}
+	// Every poll must reach the server
+	requestOptions.NoCache = true
+	query, err := QueryEntitiesOf[Event](ctx, follower.client,
+		fmt.Sprintf("%s/events/list", follower.clusterID), requestOptions)
+	if err != nil {
+		return nil, err
+	}
+	return query, nil
+}
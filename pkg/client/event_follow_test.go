@@ -0,0 +1,127 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+var errStopFollowing = errors.New("stop following")
+
+// followEvents follows the events of the active cluster until n events are
+// received, and returns their IDs
+func followEvents(t *testing.T, api *client.Client, options *client.EventQueryOptions, n int) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var ids []string
+	err := api.FollowEvents(ctx, fakehome.ActiveClusterID, options, &client.FollowOptions{Interval: 10 * time.Millisecond},
+		func(event *client.Event) error {
+			ids = append(ids, event.ID)
+			if len(ids) == n {
+				return errStopFollowing
+			}
+			return nil
+		})
+	if !errors.Is(err, errStopFollowing) {
+		t.Fatalf("expected to stop following after %d events, got %v after %q", n, err, ids)
+	}
+	return ids
+}
+
+// addEvents adds events ingested one second apart from now on, once the
+// server has received the given number of requests
+func addEvents(server *fakehome.Server, afterRequests int, params ...string) {
+	for len(server.Requests()) < afterRequests {
+		time.Sleep(time.Millisecond)
+	}
+	now := time.Now()
+	server.WithFixtures(func(fixtures *fakehome.Fixtures) {
+		for i, eventParams := range params {
+			fixtures.Events = append(fixtures.Events, client.Event{
+				ID:         fmt.Sprintf("new-%d", i),
+				CloudID:    fmt.Sprintf("new-%d", i),
+				ClusterID:  fakehome.ActiveClusterID,
+				EventType:  "NodeDisconnected",
+				Params:     json.RawMessage(eventParams),
+				Permission: "USER",
+				Severity:   "MAJOR",
+				Time:       now,
+				IngestTime: now.Add(time.Duration(i+1) * time.Second),
+			})
+		}
+	})
+}
+
+func TestFollowEvents(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	// Only events ingested once following has started are followed
+	go addEvents(server, 3, `{}`, `{}`)
+	ids := followEvents(t, server.Client(), nil, 2)
+	if ids[0] != "new-0" || ids[1] != "new-1" {
+		t.Errorf("expected the new events oldest first, got %q", ids)
+	}
+}
+
+func TestFollowEventsFromStartTime(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	var newest time.Time
+	server.WithFixtures(func(fixtures *fakehome.Fixtures) {
+		newest = fixtures.Events[len(fixtures.Events)-1].IngestTime
+	})
+	options := &client.EventQueryOptions{StartTime: newest.Add(-150 * time.Second)}
+	ids := followEvents(t, server.Client(), options, 3)
+	if fmt.Sprint(ids) != "[1117 1118 1119]" {
+		t.Errorf("expected the events ingested from the start time, oldest first, got %q", ids)
+	}
+}
+
+func TestFollowEventsFiltersParams(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	filter, err := client.ParseParamFilter("hostname=backend-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go addEvents(server, 3, `{"hostname":"backend-0"}`, `{"hostname":"backend-1"}`, `{"hostname":"backend-1"}`)
+	ids := followEvents(t, server.Client(), &client.EventQueryOptions{Params: []client.ParamFilter{filter}}, 2)
+	if ids[0] != "new-1" || ids[1] != "new-2" {
+		t.Errorf("expected only the events matching the filter, got %q", ids)
+	}
+}
+
+func TestFollowEventsRetriesErrors(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var retries []time.Duration
+	followOptions := &client.FollowOptions{
+		Interval:   time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		OnError: func(err error, retryIn time.Duration) {
+			if !client.IsNotFound(err) {
+				t.Errorf("unexpected error %v", err)
+			}
+			if retries = append(retries, retryIn); len(retries) == 4 {
+				cancel()
+			}
+		},
+	}
+	err := server.Client().FollowEvents(ctx, "no-such-cluster", nil, followOptions,
+		func(event *client.Event) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected following to be cancelled, got %v", err)
+	}
+	if fmt.Sprint(retries) != "[2ms 4ms 4ms 4ms]" {
+		t.Errorf("expected exponential backoff, got %v", retries)
+	}
+}
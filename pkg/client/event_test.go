@@ -0,0 +1,98 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+// queryEventIDs queries the events of the active cluster, and returns their
+// IDs
+func queryEventIDs(t *testing.T, api *client.Client, options *client.EventQueryOptions, limit int) []string {
+	t.Helper()
+	query, err := api.QueryEvents(context.Background(), fakehome.ActiveClusterID, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer query.Close()
+	events, err := query.Collect(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func TestQueryEventsAscending(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	// The newest events are returned, oldest first
+	truncatedAt := 0
+	onTruncated := func(returned int) { truncatedAt = returned }
+	options := &client.EventQueryOptions{SortOrder: client.SortAscending, Limit: 5, OnTruncated: onTruncated}
+	ids := queryEventIDs(t, api, options, 0)
+	if fmt.Sprint(ids) != "[1115 1116 1117 1118 1119]" {
+		t.Errorf("expected the 5 newest events oldest first, got %q", ids)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || !strings.Contains(requests[0], "page_size=6") {
+		t.Errorf("expected the events to be fetched in a single request, got %q", requests)
+	}
+	if truncatedAt != 5 {
+		t.Errorf("expected the older events to be reported as left out, got %d", truncatedAt)
+	}
+
+	truncatedAt = 0
+	ids = queryEventIDs(t, api, &client.EventQueryOptions{SortOrder: client.SortAscending, OnTruncated: onTruncated}, 0)
+	if len(ids) != fakehome.NumActiveEvents || ids[0] != "1000" || ids[len(ids)-1] != "1119" {
+		t.Errorf("expected all events oldest first, got %d events from %s", len(ids), ids[0])
+	}
+	if truncatedAt != 0 {
+		t.Errorf("expected no events to be left out, got %d", truncatedAt)
+	}
+	// Server filters still apply
+	options = &client.EventQueryOptions{SortOrder: client.SortAscending, Limit: 2, MinSeverity: "CRITICAL"}
+	if ids := queryEventIDs(t, api, options, 0); fmt.Sprint(ids) != "[1114 1119]" {
+		t.Errorf("expected the 2 newest critical events oldest first, got %q", ids)
+	}
+}
+
+func TestQueryEventsAscendingMaxEvents(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	server.WithFixtures(func(fixtures *fakehome.Fixtures) {
+		oldest := fixtures.Events[0]
+		for i := fakehome.NumActiveEvents; i <= client.MaxAscendingEvents; i++ {
+			event := oldest
+			event.ID = fmt.Sprintf("old-%d", i)
+			event.Time = oldest.Time.Add(-time.Duration(i) * time.Second)
+			fixtures.Events = append(fixtures.Events, event)
+		}
+	})
+	truncatedAt := 0
+	options := &client.EventQueryOptions{
+		SortOrder:   client.SortAscending,
+		OnTruncated: func(returned int) { truncatedAt = returned },
+	}
+	ids := queryEventIDs(t, api, options, 0)
+	if len(ids) != client.MaxAscendingEvents || ids[len(ids)-1] != "1119" {
+		t.Errorf("expected the %d newest events, got %d events to %s", client.MaxAscendingEvents, len(ids), ids[len(ids)-1])
+	}
+	if truncatedAt != client.MaxAscendingEvents {
+		t.Errorf("expected the oldest event to be reported as left out, got %d", truncatedAt)
+	}
+	if _, err := api.QueryEvents(context.Background(), fakehome.ActiveClusterID,
+		&client.EventQueryOptions{SortOrder: "sideways"}); err == nil {
+		t.Errorf("expected an invalid sort order error")
+	}
+}
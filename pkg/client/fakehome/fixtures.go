@@ -0,0 +1,132 @@
+package fakehome
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/weka/gohomecli/pkg/client"
+)
+
+// Fixtures is the data served by a fake Weka Home server. Events and diags
+// belong to the cluster named by their ClusterID field; analytics, usage
+// reports and diag file contents are keyed by cluster ID and file name
+// respectively.
+type Fixtures struct {
+	Status       client.ServerStatus        `json:"status"`
+	DBStatus     json.RawMessage            `json:"db_status"`
+	Customers    []client.Customer          `json:"customers"`
+	Clusters     []client.Cluster           `json:"clusters"`
+	Integrations []client.Integration       `json:"integrations"`
+	Events       []client.Event             `json:"events"`
+	Diags        []client.Diag              `json:"diags"`
+	DiagContents map[string]string          `json:"diag_contents"`
+	Analytics    map[string]json.RawMessage `json:"analytics"`
+	UsageReports map[string]json.RawMessage `json:"usage_reports"`
+}
+
+// LoadFixtures reads fixtures from a JSON file
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	fixtures := &Fixtures{}
+	if err := json.Unmarshal(data, fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+	}
+	return fixtures, nil
+}
+
+// Well known values of the default fixtures
+const (
+	CustomerID           = "5b1d6a2e-1a3c-4c39-9a43-6ef2b0f2d001"
+	ActiveClusterID      = "0f0c3b8e-6d1e-4a3b-8a5f-7a1c2b3d4e01"
+	InactiveClusterID    = "0f0c3b8e-6d1e-4a3b-8a5f-7a1c2b3d4e02"
+	MutedClusterID       = "0f0c3b8e-6d1e-4a3b-8a5f-7a1c2b3d4e03"
+	IntegrationID        = 1
+	DiagsTopicID         = "collection-1"
+	NumActiveEvents      = 120
+	DefaultServerVersion = "1.0.0"
+)
+
+// DefaultFixtures returns a small data set with one customer, three clusters
+// (active, inactive and muted), an integration, and events and diags for the
+// active cluster. Times are relative to now, so that "active cluster"
+// filters behave as they would against a real server.
+func DefaultFixtures() *Fixtures {
+	now := time.Now().UTC().Truncate(time.Second)
+	fixtures := &Fixtures{
+		Status:   client.ServerStatus{Active: true, Version: DefaultServerVersion},
+		DBStatus: json.RawMessage(`{"connected":true,"migrations":"up to date"}`),
+		Customers: []client.Customer{
+			{ID: CustomerID, Name: "Acme", Monitored: true, UpdatedAt: now.Add(-30 * 24 * time.Hour)},
+		},
+		Clusters: []client.Cluster{
+			{ID: ActiveClusterID, Name: "acme-prod", CustomerID: CustomerID, Version: "4.2.1",
+				CreatedAt: now.Add(-365 * 24 * time.Hour), LastSeen: now.Add(-time.Minute),
+				LastEvent: now.Add(-time.Minute)},
+			{ID: InactiveClusterID, Name: "acme-old", CustomerID: CustomerID, Version: "3.14.0",
+				CreatedAt: now.Add(-3 * 365 * 24 * time.Hour), LastSeen: now.Add(-90 * 24 * time.Hour)},
+			{ID: MutedClusterID, Name: "acme-lab", CustomerID: CustomerID, Version: "4.2.0",
+				CreatedAt: now.Add(-30 * 24 * time.Hour), LastSeen: now.Add(-time.Hour),
+				Muted: true, MuteTime: now.Add(24 * time.Hour)},
+		},
+		Integrations: []client.Integration{
+			newIntegration(IntegrationID, "ops-email", "email", "severity", `"MAJOR"`,
+				[]string{"ops@example.com"}),
+		},
+		DiagContents: make(map[string]string),
+		Analytics: map[string]json.RawMessage{
+			ActiveClusterID: json.RawMessage(`{"capacity":{"total_bytes":1099511627776,"used_bytes":549755813888}}`),
+		},
+		UsageReports: map[string]json.RawMessage{
+			ActiveClusterID: json.RawMessage(`{"licensed_capacity_bytes":1099511627776,"used_bytes":549755813888}`),
+		},
+	}
+	severities := []string{"INFO", "WARNING", "MINOR", "MAJOR", "CRITICAL"}
+	eventTypes := []string{"NodeDisconnected", "DriveActivated", "FilesystemCapacity", "ClusterUpgraded"}
+	for i := 0; i < NumActiveEvents; i++ {
+		timestamp := now.Add(-time.Duration(NumActiveEvents-i) * time.Minute)
+		fixtures.Events = append(fixtures.Events, client.Event{
+			ID:         fmt.Sprintf("%d", 1000+i),
+			CloudID:    fmt.Sprintf("8c1e4f2a-0000-4000-8000-%012d", i),
+			ClusterID:  ActiveClusterID,
+			EventType:  eventTypes[i%len(eventTypes)],
+			Category:   "System",
+			IsBackend:  true,
+			Params:     json.RawMessage(fmt.Sprintf(`{"hostname":"backend-%d","driveId":"%d"}`, i%4, i%8)),
+			NodeID:     fmt.Sprintf("NodeId<%d>", i%4),
+			Permission: "USER",
+			Severity:   severities[i%len(severities)],
+			Time:       timestamp,
+			IngestTime: timestamp.Add(2 * time.Second),
+			Processed:  true,
+		})
+	}
+	for i := 0; i < 3; i++ {
+		fileName := fmt.Sprintf("diags-backend-%d.tar.gz", i)
+		fixtures.Diags = append(fixtures.Diags, client.Diag{
+			ID:         i + 1,
+			FileName:   fileName,
+			ClusterID:  ActiveClusterID,
+			HostName:   fmt.Sprintf("backend-%d", i),
+			Completed:  true,
+			Topic:      "diags",
+			TopicId:    DiagsTopicID,
+			UploadTime: now.Add(-time.Duration(i+1) * time.Hour),
+		})
+		fixtures.DiagContents[fileName] = fmt.Sprintf("diagnostics of backend-%d\n", i)
+	}
+	return fixtures
+}
+
+func newIntegration(id int, name string, integrationType string, ruleType string, param string,
+	destinations []string) client.Integration {
+	integration := client.Integration{ID: id, Name: name}
+	integration.Configuration.Type = integrationType
+	integration.Configuration.Rule = client.IntegrationRule{RuleType: ruleType, Param: json.RawMessage(param)}
+	integration.Configuration.Destinations = destinations
+	return integration
+}
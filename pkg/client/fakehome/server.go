@@ -0,0 +1,663 @@
+// Package fakehome implements an in-process fake Weka Home server, serving
+// the API endpoints used by pkg/client from fixtures. It is meant for tests
+// and offline development:
+//
+//	server := fakehome.New(nil)
+//	defer server.Close()
+//	api := server.Client()
+//	cluster, err := api.GetCluster(ctx, fakehome.ActiveClusterID)
+package fakehome
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelletier/go-toml"
+
+	"github.com/weka/gohomecli/internal/env"
+	"github.com/weka/gohomecli/pkg/client"
+)
+
+// DefaultAPIKey is the API key accepted by servers created with New
+const DefaultAPIKey = "fakehome-api-key"
+
+// SiteName is the name of the site written by ConfigureCLI
+const SiteName = "fakehome"
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// Server is a fake Weka Home server. Its fixtures may be modified by tests
+// while it is running, as long as the modification is done in WithFixtures.
+type Server struct {
+	*httptest.Server
+	APIKey string
+
+	mutex    sync.Mutex
+	fixtures *Fixtures
+	requests []string
+}
+
+// New starts a fake server serving the given fixtures, or DefaultFixtures if
+// fixtures is nil. Call Close to shut it down.
+func New(fixtures *Fixtures) *Server {
+	if fixtures == nil {
+		fixtures = DefaultFixtures()
+	}
+	server := &Server{APIKey: DefaultAPIKey, fixtures: fixtures}
+	server.Server = httptest.NewServer(server)
+	return server
+}
+
+// Client returns an API client for the server, with retries disabled so that
+// errors surface immediately
+func (server *Server) Client() *client.Client {
+	api := client.NewClient(server.URL, server.APIKey)
+	api.RetryPolicy.MaxAttempts = 1
+	return api
+}
+
+// ConfigureCLI points the CLI at the server by writing a configuration file,
+// with the server as the default site, into configDir (typically a temporary
+// directory) and using it instead of the user's configuration
+func (server *Server) ConfigureCLI(configDir string) error {
+	env.ConfigDir = configDir + string(filepath.Separator)
+	env.ConfigFilePath = filepath.Join(configDir, "config.toml")
+	env.AliasesFilePath = filepath.Join(configDir, "aliases.toml")
+	data, err := toml.Marshal(env.Config{
+		DefaultSite: SiteName,
+		Sites: map[string]*env.SiteConfig{
+			SiteName: {APIKey: server.APIKey, CloudURL: server.URL},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(env.ConfigFilePath, data, 0644); err != nil {
+		return err
+	}
+	env.ResetConfig()
+	return nil
+}
+
+// WithFixtures calls f with the server's fixtures, while no request is
+// reading or modifying them
+func (server *Server) WithFixtures(f func(fixtures *Fixtures)) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	f(server.fixtures)
+}
+
+// Requests returns the method, path and query of all requests received so
+// far, e.g. "GET /api/v3/clusters?page=1&page_size=50"
+func (server *Server) Requests() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string(nil), server.requests...)
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The request is read and the response is built in memory, so that the
+	// fixtures are not locked while waiting for a slow client
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	server.serve(response, r)
+	for key, values := range response.Header() {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(response.Code)
+	w.Write(response.Body.Bytes())
+}
+
+func (server *Server) serve(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.requests = append(server.requests, fmt.Sprintf("%s %s", r.Method, r.URL.RequestURI()))
+	// Echo the client's request ID, if any, so that responses can be correlated
+	if requestID := r.Header.Get("X-Request-ID"); requestID != "" {
+		w.Header().Set("X-Request-ID", requestID)
+	} else {
+		w.Header().Set("X-Request-ID", strconv.Itoa(len(server.requests)))
+	}
+	if server.APIKey != "" && r.Header.Get("Authorization") != "Token "+server.APIKey {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "invalid API key")
+		return
+	}
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/api/v3/"):
+		server.serveV3(w, r, splitPath(strings.TrimPrefix(path, "/api/v3/")))
+	case strings.HasPrefix(path, "/api/"):
+		server.serveLegacy(w, r, splitPath(strings.TrimPrefix(path, "/api/")))
+	default:
+		writeError(w, http.StatusNotFound, "Not Found", "no such endpoint")
+	}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// route matches path segments against a pattern, in which "*" matches any
+// single segment. Matched segments are returned in order.
+func route(segments []string, pattern ...string) ([]string, bool) {
+	if len(segments) != len(pattern) {
+		return nil, false
+	}
+	var params []string
+	for i, part := range pattern {
+		if part == "*" {
+			params = append(params, segments[i])
+		} else if part != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (server *Server) serveV3(w http.ResponseWriter, r *http.Request, segments []string) {
+	if params, ok := route(segments, "integrations", "*", "test"); ok {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		server.testIntegration(w, r, params[0])
+		return
+	}
+	if params, ok := route(segments, "clusters", "*"); ok && r.Method == http.MethodPatch {
+		server.updateCluster(w, r, params[0])
+		return
+	}
+	if _, ok := route(segments, "integrations"); ok && r.Method == http.MethodPost {
+		server.createIntegration(w, r)
+		return
+	}
+	if params, ok := route(segments, "integrations", "*"); ok && r.Method != http.MethodGet {
+		switch r.Method {
+		case http.MethodPatch:
+			server.updateIntegration(w, r, params[0])
+		case http.MethodDelete:
+			server.deleteIntegration(w, params[0])
+		default:
+			allowMethod(w, r, http.MethodGet)
+		}
+		return
+	}
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if _, ok := route(segments, "status"); ok {
+		writeJSON(w, http.StatusOK, server.fixtures.Status)
+	} else if _, ok := route(segments, "db", "status"); ok {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": server.fixtures.DBStatus})
+	} else if _, ok := route(segments, "clusters"); ok {
+		server.listClusters(w, r)
+	} else if params, ok := route(segments, "clusters", "*"); ok {
+		server.getCluster(w, params[0])
+	} else if params, ok := route(segments, "clusters", "*", "analytics"); ok {
+		server.getClusterData(w, params[0], server.fixtures.Analytics)
+	} else if params, ok := route(segments, "clusters", "*", "latest-usage-report"); ok {
+		server.getClusterData(w, params[0], server.fixtures.UsageReports)
+	} else if params, ok := route(segments, "clusters", "*", "support", "files"); ok {
+		server.listDiags(w, r, params[0])
+	} else if params, ok := route(segments, "clusters", "*", "support", "files", "*", "content"); ok {
+		server.getDiagContent(w, params[0], params[1])
+	} else if _, ok := route(segments, "customers"); ok {
+		server.listCustomers(w, r)
+	} else if params, ok := route(segments, "customers", "*"); ok {
+		server.getCustomer(w, params[0])
+	} else if _, ok := route(segments, "integrations"); ok {
+		server.listIntegrations(w, r)
+	} else if params, ok := route(segments, "integrations", "*"); ok {
+		server.getIntegration(w, params[0])
+	} else {
+		writeError(w, http.StatusNotFound, "Not Found", "no such endpoint")
+	}
+}
+
+func (server *Server) serveLegacy(w http.ResponseWriter, r *http.Request, segments []string) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if params, ok := route(segments, "*", "events", "list"); ok {
+		server.listEvents(w, r, params[0])
+	} else if params, ok := route(segments, "events", "*"); ok {
+		server.getEvent(w, params[0])
+	} else {
+		writeError(w, http.StatusNotFound, "Not Found", "no such endpoint")
+	}
+}
+
+func (server *Server) findCluster(id string) *client.Cluster {
+	for i := range server.fixtures.Clusters {
+		if server.fixtures.Clusters[i].ID == id {
+			return &server.fixtures.Clusters[i]
+		}
+	}
+	return nil
+}
+
+func (server *Server) listClusters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var entities []entity
+	for _, cluster := range server.fixtures.Clusters {
+		if value := query.Get("muted"); value != "" && strconv.FormatBool(cluster.Muted) != value {
+			continue
+		}
+		if value := query.Get("seen_within_seconds"); value != "" {
+			seconds, _ := strconv.Atoi(value)
+			if time.Since(cluster.LastSeen) > time.Duration(seconds)*time.Second {
+				continue
+			}
+		}
+		if value := query.Get("monitored"); value != "" {
+			customer := server.findCustomer(cluster.CustomerID)
+			if customer == nil || strconv.FormatBool(customer.Monitored) != value {
+				continue
+			}
+		}
+		entities = append(entities, entity{cluster.ID, "cluster", cluster})
+	}
+	writeEntityPage(w, r, entities)
+}
+
+func (server *Server) getCluster(w http.ResponseWriter, id string) {
+	cluster := server.findCluster(id)
+	if cluster == nil {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("cluster %s not found", id))
+		return
+	}
+	writeEntity(w, http.StatusOK, entity{cluster.ID, "cluster", cluster})
+}
+
+func (server *Server) updateCluster(w http.ResponseWriter, r *http.Request, id string) {
+	cluster := server.findCluster(id)
+	if cluster == nil {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("cluster %s not found", id))
+		return
+	}
+	attributes, err := readRawAttributes(r)
+	if err == nil {
+		if err = json.Unmarshal(attributes, cluster); err != nil {
+			err = fmt.Errorf("invalid attributes: %w", err)
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	// Unmarshalling null leaves times unchanged, while null clears them
+	fields := map[string]json.RawMessage{}
+	json.Unmarshal(attributes, &fields)
+	if muteTime, exists := fields["mute_time"]; exists && string(muteTime) == "null" {
+		cluster.MuteTime = time.Time{}
+	}
+	writeEntity(w, http.StatusOK, entity{cluster.ID, "cluster", cluster})
+}
+
+func (server *Server) getClusterData(w http.ResponseWriter, clusterID string, data map[string]json.RawMessage) {
+	if server.findCluster(clusterID) == nil {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("cluster %s not found", clusterID))
+		return
+	}
+	clusterData, exists := data[clusterID]
+	if !exists {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("no data for cluster %s", clusterID))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": clusterData})
+}
+
+func (server *Server) findCustomer(id string) *client.Customer {
+	for i := range server.fixtures.Customers {
+		if server.fixtures.Customers[i].ID == id {
+			return &server.fixtures.Customers[i]
+		}
+	}
+	return nil
+}
+
+func (server *Server) listCustomers(w http.ResponseWriter, r *http.Request) {
+	var entities []entity
+	for _, customer := range server.fixtures.Customers {
+		entities = append(entities, entity{customer.ID, "customer", customer})
+	}
+	writeEntityPage(w, r, entities)
+}
+
+func (server *Server) getCustomer(w http.ResponseWriter, id string) {
+	customer := server.findCustomer(id)
+	if customer == nil {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("customer %s not found", id))
+		return
+	}
+	writeEntity(w, http.StatusOK, entity{customer.ID, "customer", customer})
+}
+
+func (server *Server) findIntegration(id string) *client.Integration {
+	for i := range server.fixtures.Integrations {
+		if strconv.Itoa(server.fixtures.Integrations[i].ID) == id {
+			return &server.fixtures.Integrations[i]
+		}
+	}
+	return nil
+}
+
+func (server *Server) listIntegrations(w http.ResponseWriter, r *http.Request) {
+	var entities []entity
+	for _, integration := range server.fixtures.Integrations {
+		entities = append(entities, entity{integration.ID, "integration", integration})
+	}
+	writeEntityPage(w, r, entities)
+}
+
+func (server *Server) getIntegration(w http.ResponseWriter, id string) {
+	integration := server.findIntegration(id)
+	if integration == nil {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("integration %s not found", id))
+		return
+	}
+	writeEntity(w, http.StatusOK, entity{integration.ID, "integration", integration})
+}
+
+func (server *Server) createIntegration(w http.ResponseWriter, r *http.Request) {
+	integration := client.Integration{}
+	if err := readAttributes(r, &integration); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	if integration.Name == "" {
+		writeError(w, http.StatusBadRequest, "Bad Request", "integration name is required")
+		return
+	}
+	integration.ID = 1
+	for _, existing := range server.fixtures.Integrations {
+		if existing.ID >= integration.ID {
+			integration.ID = existing.ID + 1
+		}
+	}
+	server.fixtures.Integrations = append(server.fixtures.Integrations, integration)
+	writeEntity(w, http.StatusCreated, entity{integration.ID, "integration", integration})
+}
+
+func (server *Server) updateIntegration(w http.ResponseWriter, r *http.Request, id string) {
+	integration := server.findIntegration(id)
+	if integration == nil {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("integration %s not found", id))
+		return
+	}
+	updated := *integration
+	if err := readAttributes(r, &updated); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	updated.ID = integration.ID
+	*integration = updated
+	writeEntity(w, http.StatusOK, entity{integration.ID, "integration", integration})
+}
+
+func (server *Server) deleteIntegration(w http.ResponseWriter, id string) {
+	for i, integration := range server.fixtures.Integrations {
+		if strconv.Itoa(integration.ID) == id {
+			server.fixtures.Integrations = append(server.fixtures.Integrations[:i], server.fixtures.Integrations[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("integration %s not found", id))
+}
+
+func (server *Server) testIntegration(w http.ResponseWriter, r *http.Request, id string) {
+	if server.findIntegration(id) == nil {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("integration %s not found", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (server *Server) listDiags(w http.ResponseWriter, r *http.Request, clusterID string) {
+	query := r.URL.Query()
+	var entities []entity
+	for _, diag := range server.fixtures.Diags {
+		if diag.ClusterID != clusterID ||
+			(query.Get("topic") != "" && diag.Topic != query.Get("topic")) ||
+			(query.Get("topic_id") != "" && diag.TopicId != query.Get("topic_id")) {
+			continue
+		}
+		entities = append(entities, entity{diag.ID, "support_file", diag})
+	}
+	writeEntityPage(w, r, entities)
+}
+
+func (server *Server) getDiagContent(w http.ResponseWriter, clusterID string, fileName string) {
+	for _, diag := range server.fixtures.Diags {
+		if diag.ClusterID == clusterID && diag.FileName == fileName {
+			content := server.fixtures.DiagContents[fileName]
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(content))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("file %s not found", fileName))
+}
+
+var severityLevels = map[string]int{
+	"DEBUG": 0, "INFO": 1, "WARNING": 2, "MINOR": 3, "MAJOR": 4, "CRITICAL": 5,
+}
+
+var nodeIDPattern = regexp.MustCompile(`^NodeId<(\d+)>$`)
+
+func (server *Server) listEvents(w http.ResponseWriter, r *http.Request, clusterID string) {
+	if server.findCluster(clusterID) == nil {
+		writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("cluster %s not found", clusterID))
+		return
+	}
+	query := r.URL.Query()
+	// Events are sorted and filtered by time of emission, or by ingest time
+	eventTime := func(event *client.Event) time.Time { return event.Time }
+	if query.Get("dt") == "t" {
+		eventTime = func(event *client.Event) time.Time { return event.IngestTime }
+	}
+	var events []client.Event
+	for _, event := range server.fixtures.Events {
+		if event.ClusterID == clusterID && eventMatches(&event, eventTime(&event), query) {
+			events = append(events, event)
+		}
+	}
+	// Newest events first
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(&events[i]).After(eventTime(&events[j]))
+	})
+	start, end := pageBounds(r, len(events))
+	writeJSON(w, http.StatusOK, append([]client.Event{}, events[start:end]...))
+}
+
+func eventMatches(event *client.Event, eventTime time.Time, query map[string][]string) bool {
+	first := func(name string) string {
+		if values := query[name]; len(values) != 0 {
+			return values[0]
+		}
+		return ""
+	}
+	if types := query["et[]"]; len(types) != 0 && !contains(types, event.EventType) {
+		return false
+	}
+	if contains(query["ex_et[]"], event.EventType) {
+		return false
+	}
+	if nodeIDs := query["node_id"]; len(nodeIDs) != 0 {
+		submatches := nodeIDPattern.FindStringSubmatch(event.NodeID)
+		if submatches == nil || !contains(nodeIDs, submatches[1]) {
+			return false
+		}
+	}
+	if severity := first("svr"); severity != "" && severityLevels[event.Severity] < severityLevels[severity] {
+		return false
+	}
+	if first("intr") != "t" && event.Permission == "INTERNAL" {
+		return false
+	}
+	if from := first("frm"); from != "" {
+		if fromTime, err := time.Parse(time.RFC3339, from); err == nil && eventTime.Before(fromTime) {
+			return false
+		}
+	}
+	if to := first("to"); to != "" {
+		if toTime, err := time.Parse(time.RFC3339, to); err == nil && eventTime.After(toTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func (server *Server) getEvent(w http.ResponseWriter, id string) {
+	for _, event := range server.fixtures.Events {
+		if event.ID == id || event.CloudID == id {
+			writeJSON(w, http.StatusOK, event)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found", fmt.Sprintf("event %s not found", id))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed",
+		fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path))
+	return false
+}
+
+// pageBounds returns the range of items in the requested page, using the
+// same 1-based "page" and "page_size" parameters as Weka Home
+func pageBounds(r *http.Request, numItems int) (start int, end int) {
+	page, pageSize := pageParams(r)
+	start = (page - 1) * pageSize
+	if start > numItems {
+		start = numItems
+	}
+	end = start + pageSize
+	if end > numItems {
+		end = numItems
+	}
+	return start, end
+}
+
+func pageParams(r *http.Request) (page int, pageSize int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err = strconv.Atoi(r.URL.Query().Get("page_size"))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+type entity struct {
+	ID         interface{}
+	Type       string
+	Attributes interface{}
+}
+
+func (e entity) envelope() map[string]interface{} {
+	return map[string]interface{}{"id": e.ID, "type": e.Type, "attributes": e.Attributes}
+}
+
+func writeEntity(w http.ResponseWriter, status int, e entity) {
+	writeJSON(w, status, map[string]interface{}{"data": e.envelope()})
+}
+
+func writeEntityPage(w http.ResponseWriter, r *http.Request, entities []entity) {
+	page, pageSize := pageParams(r)
+	start, end := pageBounds(r, len(entities))
+	data := make([]map[string]interface{}, 0, end-start)
+	for _, e := range entities[start:end] {
+		data = append(data, e.envelope())
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": data,
+		"meta": map[string]interface{}{"page": page, "page_size": pageSize},
+	})
+}
+
+// readAttributes decodes the attributes of a JSON:API request body into
+// target. Attributes missing from the request are left unchanged.
+func readAttributes(r *http.Request, target interface{}) error {
+	attributes, err := readRawAttributes(r)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(attributes, target); err != nil {
+		return fmt.Errorf("invalid attributes: %w", err)
+	}
+	return nil
+}
+
+// readRawAttributes returns the attributes of a JSON:API request body
+func readRawAttributes(r *http.Request) (json.RawMessage, error) {
+	request := struct {
+		Data struct {
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if len(request.Data.Attributes) == 0 {
+		return nil, fmt.Errorf("invalid request body: missing data.attributes")
+	}
+	return request.Data.Attributes, nil
+}
+
+func writeError(w http.ResponseWriter, status int, title string, detail string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []client.ErrorObject{{Status: strconv.Itoa(status), Title: title, Detail: detail}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
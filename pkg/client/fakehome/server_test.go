@@ -0,0 +1,321 @@
+package fakehome_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func newServer(t *testing.T) (*fakehome.Server, *client.Client) {
+	t.Helper()
+	server := fakehome.New(nil)
+	t.Cleanup(server.Close)
+	return server, server.Client()
+}
+
+func TestGetServerStatus(t *testing.T) {
+	_, api := newServer(t)
+	status, err := api.GetServerStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Active || status.Version != fakehome.DefaultServerVersion {
+		t.Errorf("unexpected status %+v", status)
+	}
+	dbStatus, err := api.GetDBStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dbStatus), `"connected":true`) {
+		t.Errorf("unexpected DB status %s", dbStatus)
+	}
+}
+
+func TestGetCluster(t *testing.T) {
+	_, api := newServer(t)
+	ctx := context.Background()
+	cluster, err := api.GetCluster(ctx, fakehome.ActiveClusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.ID != fakehome.ActiveClusterID || cluster.Name != "acme-prod" {
+		t.Errorf("unexpected cluster %+v", cluster)
+	}
+	customer, err := api.GetClusterCustomer(ctx, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if customer.ID != fakehome.CustomerID {
+		t.Errorf("expected customer %s, got %s", fakehome.CustomerID, customer.ID)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server, api := newServer(t)
+	ctx := context.Background()
+	_, err := api.GetCluster(ctx, "no-such-cluster")
+	if !client.IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	unauthorized := client.NewClient(server.URL, "wrong-key")
+	unauthorized.RetryPolicy.MaxAttempts = 1
+	_, err = unauthorized.GetCluster(ctx, fakehome.ActiveClusterID)
+	if !client.IsUnauthorized(err) {
+		t.Errorf("expected an unauthorized error, got %v", err)
+	}
+}
+
+func TestQueryClustersPaging(t *testing.T) {
+	server, api := newServer(t)
+	query, err := api.QueryClusters(context.Background(), &client.RequestOptions{PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters, err := query.Collect(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(clusters))
+	for i, cluster := range clusters {
+		ids[i] = cluster.ID
+	}
+	expected := []string{fakehome.ActiveClusterID, fakehome.InactiveClusterID, fakehome.MutedClusterID}
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected clusters %v, got %v", expected, ids)
+	}
+	// The last page is full, so an empty page ends the query
+	requests := server.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("expected 4 requests, got %v", requests)
+	}
+	for i, request := range requests {
+		if expected := fmt.Sprintf("GET /api/v3/clusters?page_size=1&page=%d", i+1); request != expected {
+			t.Errorf("expected request %s, got %s", expected, request)
+		}
+	}
+}
+
+func TestQueryClustersPrefetch(t *testing.T) {
+	_, api := newServer(t)
+	query, err := api.QueryClusters(context.Background(), &client.RequestOptions{PageSize: 1, Prefetch: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer query.Close()
+	clusters, err := query.Collect(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 3 || clusters[0].ID != fakehome.ActiveClusterID || clusters[2].ID != fakehome.MutedClusterID {
+		t.Errorf("unexpected clusters %v", clusters)
+	}
+}
+
+func TestQueryActiveClusters(t *testing.T) {
+	_, api := newServer(t)
+	query, err := api.QueryClusters(context.Background(),
+		&client.RequestOptions{Params: client.GetActiveClustersParams()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters, err := query.Collect(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters[0].ID != fakehome.ActiveClusterID {
+		t.Errorf("expected only the active cluster, got %v", clusters)
+	}
+}
+
+func TestQueryCustomers(t *testing.T) {
+	_, api := newServer(t)
+	query, err := api.QueryCustomers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	customers, err := query.Collect(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(customers) != 1 || customers[0].Name != "Acme" {
+		t.Errorf("unexpected customers %v", customers)
+	}
+}
+
+func TestClusterData(t *testing.T) {
+	_, api := newServer(t)
+	ctx := context.Background()
+	analytics, err := api.GetAnalytics(ctx, fakehome.ActiveClusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(analytics) || !strings.Contains(string(analytics), "capacity") {
+		t.Errorf("unexpected analytics %s", analytics)
+	}
+	report, err := api.GetUsageReport(ctx, fakehome.ActiveClusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "licensed_capacity_bytes") {
+		t.Errorf("unexpected usage report %s", report)
+	}
+	if _, err := api.GetAnalytics(ctx, fakehome.InactiveClusterID); !client.IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestQueryEvents(t *testing.T) {
+	_, api := newServer(t)
+	query, err := api.QueryEvents(context.Background(), fakehome.ActiveClusterID,
+		&client.EventQueryOptions{Limit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := query.Collect(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != fakehome.NumActiveEvents {
+		t.Fatalf("expected %d events, got %d", fakehome.NumActiveEvents, len(events))
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Time.After(events[i-1].Time) {
+			t.Fatalf("events are not sorted newest first: %s after %s", events[i].Time, events[i-1].Time)
+		}
+	}
+}
+
+func TestQueryEventsFilters(t *testing.T) {
+	_, api := newServer(t)
+	start := time.Now().Add(-time.Hour)
+	query, err := api.QueryEvents(context.Background(), fakehome.ActiveClusterID, &client.EventQueryOptions{
+		MinSeverity:  "MAJOR",
+		IncludeTypes: []string{"NodeDisconnected", "DriveActivated"},
+		NodeIDs:      []int{1},
+		StartTime:    start,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := query.Collect(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 {
+		t.Fatal("expected some events")
+	}
+	for _, event := range events {
+		if (event.Severity != "MAJOR" && event.Severity != "CRITICAL") ||
+			(event.EventType != "NodeDisconnected" && event.EventType != "DriveActivated") ||
+			event.NodeID != "NodeId<1>" || event.Time.Before(start) {
+			t.Errorf("event does not match the filters: %+v", event)
+		}
+	}
+}
+
+func TestGetEvent(t *testing.T) {
+	_, api := newServer(t)
+	event, err := api.GetEvent(context.Background(), fakehome.ActiveClusterID, "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ClusterID != fakehome.ActiveClusterID || event.EventType != "NodeDisconnected" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestDownloadDiags(t *testing.T) {
+	_, api := newServer(t)
+	ctx := context.Background()
+	query, err := api.QueryDiags(ctx, fakehome.ActiveClusterID,
+		&client.RequestOptions{Params: client.GetDiagsParams("diags", fakehome.DiagsTopicID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := query.Collect(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 3 {
+		t.Fatalf("expected 3 diags, got %d", len(diags))
+	}
+	fileName := filepath.Join(t.TempDir(), diags[0].FileName)
+	url := fmt.Sprintf("clusters/%s/support/files/%s/content", fakehome.ActiveClusterID, diags[0].FileName)
+	if err := api.Download(ctx, url, fileName, nil); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "diagnostics of backend-0\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestWithFixtures(t *testing.T) {
+	server, api := newServer(t)
+	server.WithFixtures(func(fixtures *fakehome.Fixtures) {
+		fixtures.Clusters[0].Name = "renamed"
+	})
+	cluster, err := api.GetCluster(context.Background(), fakehome.ActiveClusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.Name != "renamed" {
+		t.Errorf("expected the modified fixtures to be served, got %s", cluster.Name)
+	}
+}
+
+// blockingWriter is a response writer that blocks writes until released,
+// like a slow client
+type blockingWriter struct {
+	header   http.Header
+	writing  chan struct{}
+	released chan struct{}
+}
+
+func (w *blockingWriter) Header() http.Header { return w.header }
+func (w *blockingWriter) WriteHeader(int)     {}
+
+func (w *blockingWriter) Write(data []byte) (int, error) {
+	close(w.writing)
+	<-w.released
+	return len(data), nil
+}
+
+func TestFixturesNotLockedBySlowClients(t *testing.T) {
+	server, _ := newServer(t)
+	w := &blockingWriter{header: http.Header{}, writing: make(chan struct{}), released: make(chan struct{})}
+	url := fmt.Sprintf("/api/v3/clusters/%s/support/files/%s/content", fakehome.ActiveClusterID, "diags-backend-0.tar.gz")
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	r.Header.Set("Authorization", "Token "+server.APIKey)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.ServeHTTP(w, r)
+	}()
+	<-w.writing
+	fixturesRead := make(chan struct{})
+	go func() {
+		server.WithFixtures(func(fixtures *fakehome.Fixtures) {})
+		close(fixturesRead)
+	}()
+	select {
+	case <-fixturesRead:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the fixtures not to be locked while the response is written")
+	}
+	close(w.released)
+	<-done
+}
@@ -1,18 +1,21 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
 
 type Integration struct {
-	ID            int    `json:"id"`
-	Name          string `json:"name"`
-	Configuration struct {
-		Type         string          `json:"type"`
-		Rule         IntegrationRule `json:"rule"`
-		Destinations []string        `json:"destinations"`
-	} `json:"configuration"`
+	ID            int                      `json:"id"`
+	Name          string                   `json:"name"`
+	Configuration IntegrationConfiguration `json:"configuration"`
+}
+
+type IntegrationConfiguration struct {
+	Type         string          `json:"type"`
+	Rule         IntegrationRule `json:"rule"`
+	Destinations []string        `json:"destinations"`
 }
 
 type IntegrationRule struct {
@@ -21,46 +24,85 @@ type IntegrationRule struct {
 }
 
 // GetIntegration returns a single integration
-func (client *Client) GetIntegration(id int) (*Integration, error) {
+func (client *Client) GetIntegration(ctx context.Context, id int) (*Integration, error) {
 	logger.Info().Int("id", id).Msg("Fetching integration")
 	integration := &Integration{}
-	err := client.GetAPIEntity("integrations", id, integration)
+	err := client.GetAPIEntity(ctx, "integrations", id, integration)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch integration %d: %s", id, err)
+		return nil, fmt.Errorf("could not fetch integration %d: %w", id, err)
 	}
 	return integration, nil
 }
 
-func (client *Client) QueryIntegrations(options *RequestOptions) (*PagedQuery, error) {
-	query, err := client.QueryEntities("integrations", options)
+func (client *Client) QueryIntegrations(ctx context.Context, options *RequestOptions) (*Query[Integration], error) {
+	query, err := QueryEntitiesOf[Integration](ctx, client, "integrations", options)
 	if err != nil {
 		return nil, err
 	}
 	return query, nil
 }
 
+// NextIntegration returns the next integration, or nil if there are no more integrations.
+//
+// Deprecated: use Query[Integration].Next instead.
 func (query *PagedQuery) NextIntegration() (*Integration, error) {
-	integration := &Integration{}
-	ok, err := query.NextEntity(integration)
+	return nextEntityOf[Integration](query, "integration")
+}
+
+// integrationAttributes are the attributes of an integration that are set
+// when creating or updating it
+type integrationAttributes struct {
+	Name          string                   `json:"name"`
+	Configuration IntegrationConfiguration `json:"configuration"`
+}
+
+// CreateIntegration creates an integration with the name and configuration of
+// the given integration, whose ID is ignored, and returns the created
+// integration
+func (client *Client) CreateIntegration(ctx context.Context, integration *Integration) (*Integration, error) {
+	logger.Info().Str("name", integration.Name).Msg("Creating integration")
+	created := &Integration{}
+	attributes := integrationAttributes{Name: integration.Name, Configuration: integration.Configuration}
+	err := client.CreateAPIEntity(ctx, "integrations", "integration", attributes, created)
+	if err != nil {
+		return nil, fmt.Errorf("could not create integration %s: %w", integration.Name, err)
+	}
+	return created, nil
+}
+
+// UpdateIntegration replaces the name and configuration of an integration
+// with those of the given integration, and returns the updated integration
+func (client *Client) UpdateIntegration(ctx context.Context, id int, integration *Integration) (*Integration, error) {
+	logger.Info().Int("id", id).Msg("Updating integration")
+	updated := &Integration{}
+	attributes := integrationAttributes{Name: integration.Name, Configuration: integration.Configuration}
+	err := client.UpdateAPIEntity(ctx, "integrations", "integration", id, attributes, updated)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get next integration: %s", err)
+		return nil, fmt.Errorf("could not update integration %d: %w", id, err)
 	}
-	if !ok {
-		return nil, nil
+	return updated, nil
+}
+
+// DeleteIntegration deletes an integration
+func (client *Client) DeleteIntegration(ctx context.Context, id int) error {
+	logger.Info().Int("id", id).Msg("Deleting integration")
+	err := client.DeleteAPIEntity(ctx, "integrations", id)
+	if err != nil {
+		return fmt.Errorf("could not delete integration %d: %w", id, err)
 	}
-	return integration, nil
+	return nil
 }
 
 type IntegrationTestRequest struct {
 	EventID string `json:"event_id"`
 }
 
-func (client *Client) TestIntegration(id int, eventCode string) error {
+func (client *Client) TestIntegration(ctx context.Context, id int, eventCode string) error {
 	logger.Info().Int("id", id).Str("event", eventCode).Msg("testing integration")
 	// TODO: This actually doesn't work, but it's exactly the same in the legacy CLI.
 	//       Need to check what the server expects and send the correct request.
 	options := &RequestOptions{Body: IntegrationTestRequest{EventID: eventCode}}
-	err := client.Post(fmt.Sprintf("integrations/%d/test", id), &json.RawMessage{}, options)
+	err := client.Post(ctx, fmt.Sprintf("integrations/%d/test", id), &json.RawMessage{}, options)
 	if err != nil {
 		return err
 	}
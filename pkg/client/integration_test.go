@@ -0,0 +1,119 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestIntegrationWrites(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	ctx := context.Background()
+	integration := &client.Integration{
+		Name: "pager",
+		Configuration: client.IntegrationConfiguration{
+			Type:         "email",
+			Rule:         client.IntegrationRule{RuleType: "severity", Param: json.RawMessage(`"CRITICAL"`)},
+			Destinations: []string{"pager@example.com"},
+		},
+	}
+	created, err := api.CreateIntegration(ctx, integration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != fakehome.IntegrationID+1 || created.Name != "pager" {
+		t.Errorf("unexpected created integration %+v", created)
+	}
+
+	integration.Name = "pager-2"
+	updated, err := api.UpdateIntegration(ctx, created.ID, integration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := api.GetIntegration(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "pager-2" || fetched.Name != "pager-2" ||
+		fetched.Configuration.Destinations[0] != "pager@example.com" {
+		t.Errorf("unexpected updated integration %+v, fetched %+v", updated, fetched)
+	}
+
+	// Deletion responds with no content
+	if err := api.DeleteIntegration(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.GetIntegration(ctx, created.ID); !client.IsNotFound(err) {
+		t.Errorf("expected the integration to be deleted, got %v", err)
+	}
+	if err := api.DeleteIntegration(ctx, created.ID); !client.IsNotFound(err) {
+		t.Errorf("expected deleting a missing integration to fail, got %v", err)
+	}
+}
+
+func TestAPIEntityWrites(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	ctx := context.Background()
+	// Results are optional
+	err := api.CreateAPIEntity(ctx, "integrations", "integration", map[string]string{"name": "hook"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.UpdateAPIEntity(ctx, "integrations", "integration", 2, map[string]string{"name": "hook-2"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.DeleteAPIEntity(ctx, "integrations", 2); err != nil {
+		t.Fatal(err)
+	}
+	err = api.CreateAPIEntity(ctx, "integrations", "integration", map[string]string{}, nil)
+	if !client.HasStatus(err, http.StatusBadRequest) {
+		t.Errorf("expected a bad request, got %v", err)
+	}
+	// Methods the resource does not allow are reported as such
+	err = api.Put(ctx, "integrations/1", nil, &client.RequestOptions{Body: map[string]string{}})
+	if !client.HasStatus(err, http.StatusMethodNotAllowed) {
+		t.Errorf("expected PUT not to be allowed, got %v", err)
+	}
+	expected := []string{
+		"POST /api/v3/integrations",
+		"PATCH /api/v3/integrations/2",
+		"DELETE /api/v3/integrations/2",
+		"POST /api/v3/integrations",
+		"PUT /api/v3/integrations/1",
+	}
+	requests := server.Requests()
+	if len(requests) != len(expected) {
+		t.Fatalf("expected requests %q, got %q", expected, requests)
+	}
+	for i := range expected {
+		if requests[i] != expected[i] {
+			t.Errorf("expected request %q, got %q", expected[i], requests[i])
+		}
+	}
+}
+
+func TestTestIntegration(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	ctx := context.Background()
+	if err := api.TestIntegration(ctx, fakehome.IntegrationID, "TestEvent"); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.TestIntegration(ctx, 99, "TestEvent"); !client.IsNotFound(err) {
+		t.Errorf("expected a missing integration not to be found, got %v", err)
+	}
+	requests := server.Requests()
+	if requests[0] != "POST /api/v3/integrations/1/test" {
+		t.Errorf("unexpected request %s", requests[0])
+	}
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// RoundTripFunc sends a request and returns its response
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the round trip of every HTTP request sent by a Client,
+// including each retry attempt and downloads. A middleware may modify the
+// request before calling next, and inspect the response and error it returns;
+// it may also skip next altogether and return its own response.
+type Middleware func(req *http.Request, next RoundTripFunc) (*http.Response, error)
+
+// Use appends middlewares to the client's chain. The first middleware of the
+// chain is the outermost, i.e. it sees requests first and responses last.
+func (client *Client) Use(middlewares ...Middleware) {
+	client.Middlewares = append(client.Middlewares, middlewares...)
+}
+
+// roundTrip sends a request through the middleware chain
+func (client *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	next := RoundTripFunc(client.HTTPClient.Do)
+	for i := len(client.Middlewares) - 1; i >= 0; i-- {
+		middleware, inner := client.Middlewares[i], next
+		next = func(req *http.Request) (*http.Response, error) {
+			return middleware(req, inner)
+		}
+	}
+	return next(req)
+}
+
+// DefaultUserAgent returns the User-Agent sent by clients, e.g.
+// "homecli/1.2.3 (linux; amd64)"
+func DefaultUserAgent() string {
+	version := env.VersionInfo.Name
+	if version == "" {
+		version = "dev"
+	}
+	return fmt.Sprintf("homecli/%s (%s; %s)", version, runtime.GOOS, runtime.GOARCH)
+}
+
+// UserAgentMiddleware sets the User-Agent header of requests that do not have
+// one already
+func UserAgentMiddleware(userAgent string) Middleware {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		if req.Header.Get("User-Agent") == "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+		return next(req)
+	}
+}
+
+// RequestIDHeader is the header carrying request IDs, which the server echoes
+// in its responses and error messages refer to
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose requests are sent with the given
+// request ID by RequestIDMiddleware, e.g. to propagate the ID of an incoming
+// request to the API requests it causes
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID set by WithRequestID, if any
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok && requestID != ""
+}
+
+// RequestIDMiddleware sets the X-Request-ID header of requests that do not
+// have one already, to the ID carried by the request context, or to a new
+// random ID
+func RequestIDMiddleware() Middleware {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		if req.Header.Get(RequestIDHeader) == "" {
+			requestID, ok := RequestIDFromContext(req.Context())
+			if !ok {
+				requestID = uuid.NewString()
+			}
+			req.Header.Set(RequestIDHeader, requestID)
+		}
+		return next(req)
+	}
+}
+
+// RequestMetrics describes a completed HTTP round trip
+type RequestMetrics struct {
+	Method    string
+	URL       string
+	RequestID string
+	// StatusCode is zero if no response was received
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// TimingMiddleware calls observe after every round trip, e.g. to export
+// request latencies and error rates as metrics. The duration covers the time
+// until response headers are received, not reading the response body.
+func TimingMiddleware(observe func(metrics RequestMetrics)) Middleware {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		start := time.Now()
+		res, err := next(req)
+		metrics := RequestMetrics{
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			RequestID: req.Header.Get(RequestIDHeader),
+			Duration:  time.Since(start),
+			Err:       err,
+		}
+		if res != nil {
+			metrics.StatusCode = res.StatusCode
+		}
+		observe(metrics)
+		return res, err
+	}
+}
+
+// Tracer starts spans around HTTP requests. It is a subset of the
+// OpenTelemetry tracing API, so that an OpenTelemetry tracer can be plugged in
+// with a thin adapter, without this package depending on OpenTelemetry.
+type Tracer interface {
+	// Start starts a span, and returns a context carrying it
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a single traced operation, see Tracer
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// TraceContextInjector is optionally implemented by a Tracer to propagate the
+// span of a request to the server, e.g. as a W3C "traceparent" header
+type TraceContextInjector interface {
+	Inject(ctx context.Context, header http.Header)
+}
+
+// TracingMiddleware wraps every round trip in a span started by tracer, with
+// attributes following the OpenTelemetry HTTP semantic conventions
+func TracingMiddleware(tracer Tracer) Middleware {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method)
+		defer span.End()
+		req = req.WithContext(ctx)
+		span.SetAttribute("http.request.method", req.Method)
+		span.SetAttribute("url.full", req.URL.String())
+		span.SetAttribute("server.address", req.URL.Hostname())
+		if injector, ok := tracer.(TraceContextInjector); ok {
+			injector.Inject(ctx, req.Header)
+		}
+		res, err := next(req)
+		if err != nil {
+			span.RecordError(err)
+			return res, err
+		}
+		span.SetAttribute("http.response.status_code", res.StatusCode)
+		if res.StatusCode >= http.StatusBadRequest {
+			span.RecordError(fmt.Errorf("HTTP %d", res.StatusCode))
+		}
+		return res, nil
+	}
+}
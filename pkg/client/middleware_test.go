@@ -0,0 +1,157 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	var calls []string
+	record := func(name string) client.Middleware {
+		return func(req *http.Request, next client.RoundTripFunc) (*http.Response, error) {
+			calls = append(calls, name+" request")
+			res, err := next(req)
+			calls = append(calls, name+" response")
+			return res, err
+		}
+	}
+	api.Use(record("outer"), record("inner"))
+	if _, err := api.GetServerStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[outer request inner request inner response outer response]"
+	if fmt.Sprint(calls) != expected {
+		t.Errorf("expected %s, got %v", expected, calls)
+	}
+}
+
+func TestMiddlewareSeesRetries(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	api.RetryPolicy = client.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	attempts := 0
+	// Fail the first attempt without sending it
+	api.Use(func(req *http.Request, next client.RoundTripFunc) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Request:    req,
+			}, nil
+		}
+		return next(req)
+	})
+	if _, err := api.GetServerStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || len(server.Requests()) != 1 {
+		t.Errorf("expected 2 attempts and 1 request, got %d and %d", attempts, len(server.Requests()))
+	}
+}
+
+func TestDefaultMiddlewares(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	var userAgent string
+	var metrics []client.RequestMetrics
+	api.Use(
+		func(req *http.Request, next client.RoundTripFunc) (*http.Response, error) {
+			userAgent = req.Header.Get("User-Agent")
+			return next(req)
+		},
+		client.TimingMiddleware(func(m client.RequestMetrics) { metrics = append(metrics, m) }),
+	)
+	ctx := client.WithRequestID(context.Background(), "request-1")
+	if _, err := api.GetServerStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.GetCluster(context.Background(), "no-such-cluster"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if userAgent != client.DefaultUserAgent() || !strings.HasPrefix(userAgent, "homecli/") {
+		t.Errorf("unexpected User-Agent %q", userAgent)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 observed requests, got %d", len(metrics))
+	}
+	if metrics[0].RequestID != "request-1" || metrics[0].StatusCode != http.StatusOK ||
+		metrics[0].Method != http.MethodGet || !strings.HasSuffix(metrics[0].URL, "/api/v3/status") {
+		t.Errorf("unexpected metrics %+v", metrics[0])
+	}
+	// Requests without a request ID in their context get a new one
+	if metrics[1].RequestID == "" || metrics[1].RequestID == "request-1" ||
+		metrics[1].StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected metrics %+v", metrics[1])
+	}
+}
+
+// fakeTracer records the attributes and errors of its spans
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	name       string
+	attributes map[string]interface{}
+	errors     []error
+	ended      bool
+}
+
+func (tracer *fakeTracer) Start(ctx context.Context, spanName string) (context.Context, client.Span) {
+	span := &fakeSpan{name: spanName, attributes: make(map[string]interface{})}
+	tracer.spans = append(tracer.spans, span)
+	return ctx, span
+}
+
+func (tracer *fakeTracer) Inject(ctx context.Context, header http.Header) {
+	header.Set("Traceparent", fmt.Sprintf("span-%d", len(tracer.spans)))
+}
+
+func (span *fakeSpan) SetAttribute(key string, value interface{}) { span.attributes[key] = value }
+func (span *fakeSpan) RecordError(err error)                      { span.errors = append(span.errors, err) }
+func (span *fakeSpan) End()                                       { span.ended = true }
+
+func TestTracingMiddleware(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	tracer := &fakeTracer{}
+	var traceparent string
+	api.Use(client.TracingMiddleware(tracer), func(req *http.Request, next client.RoundTripFunc) (*http.Response, error) {
+		traceparent = req.Header.Get("Traceparent")
+		return next(req)
+	})
+	if _, err := api.GetCluster(context.Background(), "no-such-cluster"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(tracer.spans) != 1 {
+		t.Fatalf("expected a single span, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.name != "HTTP GET" || !span.ended || len(span.errors) != 1 {
+		t.Errorf("unexpected span %+v", span)
+	}
+	if span.attributes["http.request.method"] != "GET" || span.attributes["http.response.status_code"] != 404 ||
+		span.attributes["server.address"] != "127.0.0.1" {
+		t.Errorf("unexpected span attributes %v", span.attributes)
+	}
+	if traceparent != "span-1" {
+		t.Errorf("expected the trace context to be injected, got %q", traceparent)
+	}
+}
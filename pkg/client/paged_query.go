@@ -1,10 +1,15 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"sync/atomic"
 )
 
-const defaultPageSize = 50
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
 
 type PagedQuery struct {
 	Client            *Client
@@ -17,9 +22,37 @@ type PagedQuery struct {
 	index             int
 	maxIndex          int
 	queryMetaParams   map[string]interface{}
+	ctx               context.Context
+	prefetcher        *pagePrefetcher
+}
+
+// queryPage holds the results of fetching a single page
+type queryPage struct {
+	number           int
+	results          queryResultsEnvelope
+	noMetaResults    []json.RawMessage
+	numResultsInPage int
+	hasMorePages     bool
+	err              error
+}
+
+// pagePrefetcher fetches pages ahead of the one being consumed. Pages are
+// delivered in order through futures, which holds at most Prefetch pages that
+// were fetched or are being fetched, bounding memory usage.
+type pagePrefetcher struct {
+	futures  chan chan *queryPage
+	cancel   context.CancelFunc
+	lastPage atomic.Int64
 }
 
-func (client *Client) QueryEntities(url string, options *RequestOptions) (*PagedQuery, error) {
+// QueryEntities starts a paged query and fetches its first page. The context
+// is kept by the query and used for fetching all subsequent pages, so
+// cancelling it stops the query.
+//
+// If options.Prefetch is set, up to that many of the following pages are
+// fetched concurrently in the background while the current page is consumed.
+// Call Close when abandoning such a query before reaching its end.
+func (client *Client) QueryEntities(ctx context.Context, url string, options *RequestOptions) (*PagedQuery, error) {
 	if options == nil {
 		options = &RequestOptions{}
 	}
@@ -29,8 +62,8 @@ func (client *Client) QueryEntities(url string, options *RequestOptions) (*Paged
 	if options.PageSize == 0 {
 		options.PageSize = defaultPageSize
 	}
-	if options.PageSize > 1000 {
-		options.PageSize = 1000
+	if options.PageSize > maxPageSize {
+		options.PageSize = maxPageSize
 	}
 	options.Params.Set("page_size", options.PageSize)
 	query := PagedQuery{
@@ -38,38 +71,123 @@ func (client *Client) QueryEntities(url string, options *RequestOptions) (*Paged
 		URL:     url,
 		Options: options,
 		Page:    0,
+		ctx:     ctx,
 	}
 	err := query.FetchNextPage()
 	if err != nil {
 		return nil, err
 	}
+	if query.HasMorePages && options.Prefetch > 0 {
+		query.startPrefetching(options.Prefetch)
+	}
 	return &query, nil
 }
 
+// newPreloadedQuery returns a query over entities that were already fetched,
+// as a single page of raw JSON
+func newPreloadedQuery(ctx context.Context, client *Client, url string, entities []json.RawMessage) *PagedQuery {
+	return &PagedQuery{
+		Client:            client,
+		URL:               url,
+		Options:           &RequestOptions{NoMetadata: true, Params: &QueryParams{}},
+		Page:              1,
+		noMetaPageResults: entities,
+		index:             -1,
+		maxIndex:          len(entities) - 1,
+		ctx:               ctx,
+	}
+}
+
+// fetchPage fetches a single page. It is safe to call concurrently.
+func (query *PagedQuery) fetchPage(ctx context.Context, number int) *queryPage {
+	page := &queryPage{number: number}
+	options := *query.Options
+	options.Params = query.Options.Params.clone().Set("page", number)
+	if options.NoMetadata {
+		page.err = query.Client.Get(ctx, query.URL, &page.noMetaResults, &options)
+		page.numResultsInPage = len(page.noMetaResults)
+		page.hasMorePages = page.numResultsInPage == options.PageSize
+	} else {
+		page.err = query.Client.Get(ctx, query.URL, &page.results, &options)
+		page.numResultsInPage = len(page.results.Data)
+		page.hasMorePages = page.numResultsInPage == page.results.Meta.PageSize
+	}
+	return page
+}
+
 func (query *PagedQuery) FetchNextPage() error {
-	query.Page++
-	query.Options.Params.Set("page", query.Page)
-	var numResultsInPage int
-	if query.Options.NoMetadata {
-		err := query.Client.Get(query.URL, &query.noMetaPageResults, query.Options)
-		if err != nil {
-			return err
-		}
-		numResultsInPage = len(query.noMetaPageResults)
-		query.HasMorePages = numResultsInPage == query.Options.PageSize
+	var page *queryPage
+	if query.prefetcher != nil {
+		page = query.prefetcher.next(query.ctx)
 	} else {
-		err := query.Client.Get(query.URL, &query.PageResults, query.Options)
-		if err != nil {
-			return err
-		}
-		numResultsInPage = len(query.PageResults.Data)
-		query.HasMorePages = numResultsInPage == query.PageResults.Meta.PageSize
+		page = query.fetchPage(query.ctx, query.Page+1)
 	}
+	if page.err != nil {
+		query.Close()
+		return page.err
+	}
+	query.Page = page.number
+	query.PageResults = page.results
+	query.noMetaPageResults = page.noMetaResults
+	query.HasMorePages = page.hasMorePages
 	query.index = -1
-	query.maxIndex = numResultsInPage - 1
+	query.maxIndex = page.numResultsInPage - 1
+	if !query.HasMorePages {
+		query.Close()
+	}
 	return nil
 }
 
+func (query *PagedQuery) startPrefetching(numPages int) {
+	ctx, cancel := context.WithCancel(query.ctx)
+	prefetcher := &pagePrefetcher{
+		futures: make(chan chan *queryPage, numPages),
+		cancel:  cancel,
+	}
+	query.prefetcher = prefetcher
+	go func(firstPage int) {
+		defer close(prefetcher.futures)
+		for number := firstPage; ; number++ {
+			if lastPage := prefetcher.lastPage.Load(); lastPage != 0 && int64(number) > lastPage {
+				return
+			}
+			future := make(chan *queryPage, 1)
+			select {
+			case prefetcher.futures <- future:
+			case <-ctx.Done():
+				return
+			}
+			go func(number int) {
+				page := query.fetchPage(ctx, number)
+				if page.err == nil && !page.hasMorePages {
+					prefetcher.lastPage.CompareAndSwap(0, int64(number))
+				}
+				future <- page
+			}(number)
+		}
+	}(query.Page + 1)
+}
+
+// next returns the next prefetched page, waiting for it if necessary
+func (prefetcher *pagePrefetcher) next(ctx context.Context) *queryPage {
+	future, ok := <-prefetcher.futures
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return &queryPage{err: err}
+		}
+		return &queryPage{err: context.Canceled}
+	}
+	return <-future
+}
+
+// Close stops any background prefetching of pages. It is called automatically
+// when the last page has been fetched or fetching a page has failed.
+func (query *PagedQuery) Close() {
+	if query.prefetcher != nil {
+		query.prefetcher.cancel()
+	}
+}
+
 func (query *PagedQuery) NextEntity(result interface{}) (ok bool, err error) {
 	if query.index == query.maxIndex {
 		if !query.HasMorePages || query.Options.NoAutoFetchNextPage {
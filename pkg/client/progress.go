@@ -0,0 +1,34 @@
+package client
+
+import "io"
+
+// ProgressReporter receives progress updates from Download and DownloadMany.
+// DownloadMany calls it from several goroutines at once, so implementations
+// must be safe for concurrent use.
+type ProgressReporter interface {
+	// Start is called whenever a transfer of fileName starts, including when
+	// an interrupted download is resumed. offset is the number of bytes
+	// already downloaded, and total is the size of the whole file, or -1 if
+	// it is unknown.
+	Start(fileName string, offset int64, total int64)
+	// Progress is called as data is written, with the number of bytes
+	// written since the previous call
+	Progress(fileName string, bytes int64)
+	// Done is called once fileName is downloaded, or has failed with err
+	Done(fileName string, err error)
+}
+
+// progressWriter reports the number of bytes written through it
+type progressWriter struct {
+	writer   io.Writer
+	fileName string
+	reporter ProgressReporter
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if n > 0 {
+		w.reporter.Progress(w.fileName, int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,67 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
+)
+
+// recordingReporter records the progress reported to it
+type recordingReporter struct {
+	mutex sync.Mutex
+	calls []string
+	bytes map[string]int64
+}
+
+func (reporter *recordingReporter) Start(fileName string, offset int64, total int64) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.calls = append(reporter.calls, fmt.Sprintf("start %s %d %d", filepath.Base(fileName), offset, total))
+}
+
+func (reporter *recordingReporter) Progress(fileName string, bytes int64) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.bytes[filepath.Base(fileName)] += bytes
+}
+
+func (reporter *recordingReporter) Done(fileName string, err error) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.calls = append(reporter.calls, fmt.Sprintf("done %s %v", filepath.Base(fileName), err != nil))
+}
+
+func TestDownloadProgress(t *testing.T) {
+	server := fakehome.New(nil)
+	defer server.Close()
+	reporter := &recordingReporter{bytes: make(map[string]int64)}
+	options := &client.RequestOptions{Progress: reporter}
+	api := server.Client()
+	ctx := context.Background()
+	dir := t.TempDir()
+	url := fmt.Sprintf("clusters/%s/support/files/diags-backend-0.tar.gz/content", fakehome.ActiveClusterID)
+	if err := api.Download(ctx, url, filepath.Join(dir, "diags-backend-0.tar.gz"), options); err != nil {
+		t.Fatal(err)
+	}
+	url = fmt.Sprintf("clusters/%s/support/files/missing.tar.gz/content", fakehome.ActiveClusterID)
+	if err := api.Download(ctx, url, filepath.Join(dir, "missing.tar.gz"), options); err == nil {
+		t.Fatal("expected the download of a missing file to fail")
+	}
+	size := int64(len("diagnostics of backend-0\n"))
+	expected := []string{
+		fmt.Sprintf("start diags-backend-0.tar.gz 0 %d", size),
+		"done diags-backend-0.tar.gz false",
+		"done missing.tar.gz true",
+	}
+	if fmt.Sprint(reporter.calls) != fmt.Sprint(expected) {
+		t.Errorf("expected calls %v, got %v", expected, reporter.calls)
+	}
+	if reporter.bytes["diags-backend-0.tar.gz"] != size {
+		t.Errorf("expected %d bytes of progress, got %d", size, reporter.bytes["diags-backend-0.tar.gz"])
+	}
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// Query is a typed iterator over the entities of a paged query. It pages
+// through results automatically (unless NoAutoFetchNextPage is set), both for
+// responses with a metadata envelope and for NoMetadata raw arrays.
+//
+//	query, err := client.QueryClusters(ctx, nil)
+//	...
+//	for query.Next() {
+//		cluster := query.Value()
+//		...
+//	}
+//	if err := query.Err(); err != nil {
+//		...
+//	}
+type Query[T any] struct {
+	*PagedQuery
+	value *T
+	err   error
+	// filter, if set, skips entities for which it returns false
+	filter func(value *T) bool
+}
+
+// NewQuery returns a typed iterator over the entities of an untyped query
+func NewQuery[T any](query *PagedQuery) *Query[T] {
+	return &Query[T]{PagedQuery: query}
+}
+
+// QueryEntitiesOf starts a paged query of entities of type T, see
+// Client.QueryEntities
+func QueryEntitiesOf[T any](ctx context.Context, client *Client, url string, options *RequestOptions) (*Query[T], error) {
+	query, err := client.QueryEntities(ctx, url, options)
+	if err != nil {
+		return nil, err
+	}
+	return NewQuery[T](query), nil
+}
+
+// Next advances to the next entity, which is then available via Value. It
+// returns false when there are no more entities or an error has occurred, in
+// which case Err returns it.
+func (query *Query[T]) Next() bool {
+	query.value = nil
+	if query.err != nil {
+		return false
+	}
+	for {
+		value := new(T)
+		ok, err := query.NextEntity(value)
+		if err != nil {
+			query.err = err
+			return false
+		}
+		if !ok {
+			return false
+		}
+		if query.filter == nil || query.filter(value) {
+			query.value = value
+			return true
+		}
+	}
+}
+
+// Value returns the current entity, as advanced to by Next
+func (query *Query[T]) Value() *T {
+	return query.value
+}
+
+// Err returns the error that stopped iteration, if any
+func (query *Query[T]) Err() error {
+	return query.err
+}
+
+// Collect returns up to limit of the remaining entities, or all of them if
+// limit is zero or negative
+func (query *Query[T]) Collect(limit int) ([]*T, error) {
+	var result []*T
+	for (limit <= 0 || len(result) < limit) && query.Next() {
+		result = append(result, query.Value())
+	}
+	return result, query.Err()
+}
+
+// ForEach calls f for each of the remaining entities, stopping early if f
+// returns an error
+func (query *Query[T]) ForEach(f func(*T) error) error {
+	for query.Next() {
+		if err := f(query.Value()); err != nil {
+			return err
+		}
+	}
+	return query.Err()
+}
+
+// nextEntityOf returns the next entity of a paged query, or nil if there are
+// no more entities. It backs the entity specific Next* methods of PagedQuery.
+func nextEntityOf[T any](query *PagedQuery, entityName string) (*T, error) {
+	entity := new(T)
+	ok, err := query.NextEntity(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next %s: %w", entityName, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return entity, nil
+}
@@ -0,0 +1,22 @@
+//go:build go1.23
+
+package client
+
+// All returns an iterator over the remaining entities, for use with
+// range-over-func:
+//
+//	for cluster := range query.All() {
+//		...
+//	}
+//	if err := query.Err(); err != nil {
+//		...
+//	}
+func (query *Query[T]) All() func(yield func(*T) bool) {
+	return func(yield func(*T) bool) {
+		for query.Next() {
+			if !yield(query.Value()) {
+				return
+			}
+		}
+	}
+}
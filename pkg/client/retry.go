@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// RetryPolicy controls how requests that failed due to transport errors or
+// transient server errors (HTTP 429 and 5xx) are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per request, including the
+	// first one. A value of 1 or less disables retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles with each
+	// further retry, with random jitter applied.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts, including delays requested
+	// by the server via the Retry-After header
+	MaxBackoff time.Duration
+	// RetryNonIdempotent enables retrying methods such as POST, which might
+	// have taken effect on the server even though the request failed
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns the retry policy used by new clients
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+	}
+}
+
+// retryPolicyFromConfig returns the default retry policy, overridden by any
+// values set in the site configuration
+func retryPolicyFromConfig(config *env.RetryConfig) (RetryPolicy, error) {
+	policy := DefaultRetryPolicy()
+	if config.MaxAttempts != 0 {
+		policy.MaxAttempts = config.MaxAttempts
+	}
+	if config.InitialBackoff != "" {
+		backoff, err := time.ParseDuration(config.InitialBackoff)
+		if err != nil {
+			return policy, fmt.Errorf("initial_backoff: %s", err)
+		}
+		policy.InitialBackoff = backoff
+	}
+	if config.MaxBackoff != "" {
+		backoff, err := time.ParseDuration(config.MaxBackoff)
+		if err != nil {
+			return policy, fmt.Errorf("max_backoff: %s", err)
+		}
+		policy.MaxBackoff = backoff
+	}
+	policy.RetryNonIdempotent = config.RetryNonIdempotent
+	return policy, nil
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests ||
+		(status >= http.StatusInternalServerError && status != http.StatusNotImplemented)
+}
+
+// shouldRetry reports whether the outcome of the given attempt (1-based)
+// warrants another attempt
+func (policy *RetryPolicy) shouldRetry(ctx context.Context, method string, attempt int,
+	res *http.Response, err error) bool {
+	if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	if !policy.RetryNonIdempotent && !isIdempotentMethod(method) {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, ErrNoRecordedResponse)
+	}
+	return isRetryableStatus(res.StatusCode)
+}
+
+// backoff returns the delay before the next attempt, after the given attempt
+// (1-based) has failed
+func (policy *RetryPolicy) backoff(attempt int, res *http.Response) time.Duration {
+	delay := policy.InitialBackoff << (attempt - 1)
+	if delay <= 0 || (policy.MaxBackoff > 0 && delay > policy.MaxBackoff) {
+		delay = policy.MaxBackoff
+	}
+	// Jitter in [delay/2, delay)
+	if delay > 1 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+	}
+	if res != nil {
+		if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok && retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number
+// of seconds or an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
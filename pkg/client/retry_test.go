@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer returns a server failing the first failures requests with
+// the given status, and the number of requests it received
+func newFlakyServer(t *testing.T, failures int32, status int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestClient(url string) *Client {
+	client := NewClient(url, "key")
+	client.RetryPolicy.InitialBackoff = time.Millisecond
+	client.RetryPolicy.MaxBackoff = 10 * time.Millisecond
+	return client
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	server, requests := newFlakyServer(t, 2, http.StatusServiceUnavailable, "")
+	result := struct{ OK bool }{}
+	if err := newTestClient(server.URL).Get(context.Background(), "status", &result, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK || requests.Load() != 3 {
+		t.Errorf("expected success after 3 requests, got %v after %d", result.OK, requests.Load())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	server, requests := newFlakyServer(t, 10, http.StatusBadGateway, "")
+	err := newTestClient(server.URL).Get(context.Background(), "status", nil, nil)
+	if !HasStatus(err, http.StatusBadGateway) {
+		t.Errorf("expected a 502 error, got %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", requests.Load())
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		method string
+		status int
+	}{
+		{"client error", http.MethodGet, http.StatusNotFound},
+		{"not implemented", http.MethodGet, http.StatusNotImplemented},
+		{"non-idempotent method", http.MethodPost, http.StatusServiceUnavailable},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := newFlakyServer(t, 10, test.status, "")
+			err := newTestClient(server.URL).SendRequest(context.Background(), test.method, "status", nil, nil)
+			if !HasStatus(err, test.status) {
+				t.Errorf("expected a %d error, got %v", test.status, err)
+			}
+			if requests.Load() != 1 {
+				t.Errorf("expected a single attempt, got %d", requests.Load())
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	server, requests := newFlakyServer(t, 1, http.StatusTooManyRequests, "1")
+	client := newTestClient(server.URL)
+	client.RetryPolicy.MaxBackoff = 2 * time.Second
+	start := time.Now()
+	if err := client.Get(context.Background(), "status", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected the retry to wait for Retry-After, waited %s", elapsed)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected 2 attempts, got %d", requests.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, maxDelay := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		delay := policy.backoff(attempt, nil)
+		if delay < maxDelay/2 || delay >= maxDelay {
+			t.Errorf("attempt %d: expected a delay in [%s, %s), got %s", attempt, maxDelay/2, maxDelay, delay)
+		}
+	}
+	res := &http.Response{Header: http.Header{"Retry-After": {"60"}}}
+	if delay := policy.backoff(1, res); delay != time.Second {
+		t.Errorf("expected Retry-After to be capped by MaxBackoff, got %s", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"0":  0,
+		"30": 30 * time.Second,
+		time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat): 0,
+	} {
+		delay, ok := parseRetryAfter(value)
+		if !ok || delay != expected {
+			t.Errorf("%q: expected %s, got %s (ok %v)", value, expected, delay, ok)
+		}
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if delay, ok := parseRetryAfter(future); !ok || delay <= 0 || delay > time.Minute {
+		t.Errorf("%q: expected up to a minute, got %s (ok %v)", future, delay, ok)
+	}
+	for _, value := range []string{"", "-1", "soon"} {
+		if _, ok := parseRetryAfter(value); ok {
+			t.Errorf("%q: expected an invalid value", value)
+		}
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	server, requests := newFlakyServer(t, 10, http.StatusServiceUnavailable, "")
+	client := newTestClient(server.URL)
+	client.RetryPolicy.InitialBackoff = time.Hour
+	client.RetryPolicy.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.Get(ctx, "status", nil, nil)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d", requests.Load())
+	}
+}
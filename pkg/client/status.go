@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -10,19 +11,19 @@ type ServerStatus struct {
 }
 
 // GetCluster returns a single cluster
-func (client *Client) GetServerStatus() (*ServerStatus, error) {
+func (client *Client) GetServerStatus(ctx context.Context) (*ServerStatus, error) {
 	logger.Info().Msg("Fetching server status")
 	status := &ServerStatus{}
-	err := client.Get("status", status, nil)
+	err := client.Get(ctx, "status", status, nil)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch server status: %s", err)
+		return nil, fmt.Errorf("could not fetch server status: %w", err)
 	}
 	return status, nil
 }
 
-func (client *Client) GetDBStatus() ([]byte, error) {
+func (client *Client) GetDBStatus(ctx context.Context) ([]byte, error) {
 	result := &rawResponse{}
-	err := client.Get("db/status", result, nil)
+	err := client.Get(ctx, "db/status", result, nil)
 	if err != nil {
 		return nil, err
 	}
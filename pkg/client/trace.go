@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultTraceBodyLimit is the number of body bytes logged by
+// HTTPTraceMiddleware
+const DefaultTraceBodyLimit = 64 * 1024
+
+// sensitiveParamPattern matches the names of query parameters whose values
+// are redacted from traces
+var sensitiveParamPattern = regexp.MustCompile(`(?i)token|password|secret|api_?key|credential`)
+
+// sensitiveJSONFieldPattern matches string JSON fields with sensitive names
+var sensitiveJSONFieldPattern = regexp.MustCompile(
+	`("[^"]*(?i:token|password|secret|api_?key|credential)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// HTTPTraceMiddleware logs the headers and bodies of requests and responses,
+// along with the duration of each round trip. Secrets are redacted: sensitive
+// headers, and query parameters and JSON string fields with names such as
+// "token" or "api_key". At most bodyLimit bytes of each body are logged, and
+// JSON bodies are pretty-printed. Response bodies are not buffered beyond
+// bodyLimit, so downloads are still streamed.
+func HTTPTraceMiddleware(bodyLimit int) Middleware {
+	return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+		var requestBody []byte
+		if req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				requestBody, _ = io.ReadAll(io.LimitReader(body, int64(bodyLimit)+1))
+				body.Close()
+			}
+		}
+		dump := &strings.Builder{}
+		fmt.Fprintf(dump, "> %s %s\n", req.Method, redactURL(req.URL))
+		writeTraceHeader(dump, "> ", req.Header)
+		writeTraceBody(dump, requestBody, req.Header.Get("Content-Type"), bodyLimit)
+		logger.Info().Msg("HTTP request\n" + strings.TrimSuffix(dump.String(), "\n"))
+
+		start := time.Now()
+		res, err := next(req)
+		duration := time.Since(start)
+		if err != nil {
+			logger.Info().
+				Str("method", req.Method).
+				Str("url", redactURL(req.URL)).
+				Dur("duration", duration).
+				Err(err).
+				Msg("HTTP request failed")
+			return res, err
+		}
+		// Peek at the beginning of the body, and hand it back to the caller
+		// followed by the rest of the body
+		responseBody, _ := io.ReadAll(io.LimitReader(res.Body, int64(bodyLimit)+1))
+		res.Body = &peekedBody{
+			Reader: io.MultiReader(bytes.NewReader(responseBody), res.Body),
+			Closer: res.Body,
+		}
+		dump.Reset()
+		fmt.Fprintf(dump, "< %s %s (%s)\n", res.Proto, res.Status, duration.Round(time.Microsecond))
+		writeTraceHeader(dump, "< ", res.Header)
+		writeTraceBody(dump, responseBody, res.Header.Get("Content-Type"), bodyLimit)
+		logger.Info().Msg("HTTP response\n" + strings.TrimSuffix(dump.String(), "\n"))
+		return res, nil
+	}
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// redactURL returns a URL with the values of sensitive query parameters and
+// any password redacted
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if _, hasPassword := u.User.Password(); hasPassword {
+		redacted.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+	query := u.Query()
+	changed := false
+	for name := range query {
+		if sensitiveParamPattern.MatchString(name) {
+			query.Set(name, redactedValue)
+			changed = true
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+func writeTraceHeader(dump *strings.Builder, prefix string, header http.Header) {
+	header = redactHeader(header)
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(dump, "%s%s: %s\n", prefix, name, value)
+		}
+	}
+}
+
+func writeTraceBody(dump *strings.Builder, body []byte, contentType string, bodyLimit int) {
+	if len(body) == 0 {
+		return
+	}
+	truncated := len(body) > bodyLimit
+	if truncated {
+		body = body[:bodyLimit]
+	}
+	dump.WriteString("\n")
+	if truncated {
+		// Drop a rune cut in the middle by truncation
+		for i := 0; i < utf8.UTFMax-1 && len(body) > 0 && !utf8.Valid(body); i++ {
+			body = body[:len(body)-1]
+		}
+	}
+	if !utf8.Valid(body) {
+		dump.WriteString("<binary data>\n")
+		return
+	}
+	body = sensitiveJSONFieldPattern.ReplaceAll(body, []byte(`$1"`+redactedValue+`"`))
+	pretty := &bytes.Buffer{}
+	if !truncated && (strings.Contains(contentType, "json") || json.Valid(body)) &&
+		json.Indent(pretty, body, "", "  ") == nil {
+		body = pretty.Bytes()
+	}
+	dump.Write(body)
+	if truncated {
+		fmt.Fprintf(dump, "\n<truncated after %d bytes>", bodyLimit)
+	}
+	dump.WriteString("\n")
+}
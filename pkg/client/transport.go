@@ -0,0 +1,78 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// usesCustomTransport returns true if a site configures TLS or proxy settings
+func usesCustomTransport(siteConfig *env.SiteConfig) bool {
+	return siteConfig.CAFile != "" || siteConfig.ClientCert != "" || siteConfig.ClientKey != "" ||
+		siteConfig.InsecureSkipVerify || siteConfig.ProxyURL != ""
+}
+
+// transportFromConfig creates an HTTP transport with the TLS and proxy
+// settings of a site
+func transportFromConfig(siteConfig *env.SiteConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{InsecureSkipVerify: siteConfig.InsecureSkipVerify}
+	if siteConfig.CAFile != "" {
+		pem, err := os.ReadFile(siteConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", siteConfig.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if siteConfig.ClientCert != "" || siteConfig.ClientKey != "" {
+		if siteConfig.ClientCert == "" || siteConfig.ClientKey == "" {
+			return nil, errors.New("client_cert and client_key must be set together")
+		}
+		certificate, err := tls.LoadX509KeyPair(siteConfig.ClientCert, siteConfig.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	transport.TLSClientConfig = tlsConfig
+	if siteConfig.ProxyURL != "" {
+		proxyURL, err := url.Parse(siteConfig.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy_url %q: scheme and host are required", siteConfig.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if siteConfig.InsecureSkipVerify {
+		logger.Debug().Msg("TLS certificate verification is disabled")
+	}
+	return transport, nil
+}
+
+// timeoutFromConfig parses the request timeout of a site
+func timeoutFromConfig(siteConfig *env.SiteConfig) (time.Duration, error) {
+	timeout, err := time.ParseDuration(siteConfig.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %w", err)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout: %s is negative", siteConfig.Timeout)
+	}
+	return timeout, nil
+}
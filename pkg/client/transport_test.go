@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weka/gohomecli/internal/env"
+)
+
+// writePEM writes a PEM block into a file in dir, and returns its path
+func writePEM(t *testing.T, dir string, name string, blockType string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key
+// into dir, and returns their paths
+func writeClientCertificate(t *testing.T, dir string) (certFile string, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "homecli"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", certificate),
+		writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyBytes)
+}
+
+// getStatus sends a request to url using the transport of a site
+// configuration
+func getStatus(t *testing.T, url string, siteConfig *env.SiteConfig) error {
+	t.Helper()
+	transport, err := transportFromConfig(siteConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(url, "key")
+	client.RetryPolicy.MaxAttempts = 1
+	client.HTTPClient.Transport = transport
+	return client.Get(context.Background(), "status", nil, nil)
+}
+
+func TestTransportCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	if err := getStatus(t, server.URL, &env.SiteConfig{}); err == nil ||
+		!strings.Contains(err.Error(), "certificate") {
+		t.Errorf("expected the server certificate to be rejected, got %v", err)
+	}
+	if err := getStatus(t, server.URL, &env.SiteConfig{CAFile: caFile}); err != nil {
+		t.Errorf("expected the server certificate to be trusted, got %v", err)
+	}
+	if err := getStatus(t, server.URL, &env.SiteConfig{InsecureSkipVerify: true}); err != nil {
+		t.Errorf("expected the server certificate not to be verified, got %v", err)
+	}
+}
+
+func TestTransportClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "homecli" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+	dir := t.TempDir()
+	certFile, keyFile := writeClientCertificate(t, dir)
+	err := getStatus(t, server.URL, &env.SiteConfig{InsecureSkipVerify: true, ClientCert: certFile, ClientKey: keyFile})
+	if err != nil {
+		t.Errorf("expected the client certificate to be sent, got %v", err)
+	}
+	err = getStatus(t, server.URL, &env.SiteConfig{InsecureSkipVerify: true})
+	if !IsForbidden(err) {
+		t.Errorf("expected no client certificate to be sent, got %v", err)
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Method+" "+r.URL.String())
+	}))
+	defer proxy.Close()
+	if err := getStatus(t, "http://home.invalid", &env.SiteConfig{ProxyURL: proxy.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if len(proxied) != 1 || proxied[0] != "GET http://home.invalid/api/v3/status" {
+		t.Errorf("expected the request to be sent through the proxy, got %v", proxied)
+	}
+}
+
+func TestTransportConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writeClientCertificate(t, dir)
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		siteConfig env.SiteConfig
+		expected   string
+	}{
+		{env.SiteConfig{CAFile: filepath.Join(dir, "missing.pem")}, "failed to read CA file"},
+		{env.SiteConfig{CAFile: notPEM}, "no certificates found"},
+		{env.SiteConfig{ClientCert: certFile}, "must be set together"},
+		{env.SiteConfig{ClientCert: certFile, ClientKey: notPEM}, "failed to load client certificate"},
+		{env.SiteConfig{ProxyURL: "proxy:3128"}, "scheme and host are required"},
+		{env.SiteConfig{ProxyURL: "http://proxy:port"}, "invalid proxy_url"},
+	} {
+		_, err := transportFromConfig(&test.siteConfig)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%+v: expected an error containing %q, got %v", test.siteConfig, test.expected, err)
+		}
+	}
+}
+
+func TestTimeoutFromConfig(t *testing.T) {
+	for text, expected := range map[string]time.Duration{"30s": 30 * time.Second, "0": 0, "2m": 2 * time.Minute} {
+		timeout, err := timeoutFromConfig(&env.SiteConfig{Timeout: text})
+		if err != nil || timeout != expected {
+			t.Errorf("%s: expected %s, got %s, %v", text, expected, timeout, err)
+		}
+	}
+	for _, text := range []string{"-1s", "soon"} {
+		if _, err := timeoutFromConfig(&env.SiteConfig{Timeout: text}); err == nil {
+			t.Errorf("%s: expected an error", text)
+		}
+	}
+}
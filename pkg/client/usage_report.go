@@ -1,10 +1,13 @@
 package client
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
-func (client *Client) GetUsageReport(clusterID string) ([]byte, error) {
+func (client *Client) GetUsageReport(ctx context.Context, clusterID string) ([]byte, error) {
 	result := &rawResponse{}
-	err := client.Get(fmt.Sprintf("clusters/%s/latest-usage-report", clusterID), result, nil)
+	err := client.Get(ctx, fmt.Sprintf("clusters/%s/latest-usage-report", clusterID), result, nil)
 	if err != nil {
 		return nil, err
 	}
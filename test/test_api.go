@@ -1,14 +1,55 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+
 	"github.com/weka/gohomecli/pkg/client"
+	"github.com/weka/gohomecli/pkg/client/fakehome"
 )
 
-const hostname = "api.fries.home.weka.io"
-const token = "Onjb8DYnP9DDVSargu11TrvEvpQEwS"
-
+// Smoke test of the API client against an in-process fake Weka Home server
 func main() {
-	client := client.NewClient(hostname, token)
-	fmt.Printf("%v", client)
+	server := fakehome.New(nil)
+	defer server.Close()
+	api := server.Client()
+	ctx := context.Background()
+
+	status, err := api.GetServerStatus(ctx)
+	check(err)
+	fmt.Printf("server version: %s\n", status.Version)
+
+	cluster, err := api.GetCluster(ctx, fakehome.ActiveClusterID)
+	check(err)
+	customer, err := api.GetClusterCustomer(ctx, cluster)
+	check(err)
+	fmt.Printf("cluster: %s (%s), customer: %s\n", cluster.Name, cluster.ID, customer.Name)
+
+	_, err = api.GetCluster(ctx, "no-such-cluster")
+	if !client.IsNotFound(err) {
+		check(fmt.Errorf("expected a not found error, got: %v", err))
+	}
+
+	clusters, err := api.QueryClusters(ctx, &client.RequestOptions{Params: client.GetActiveClustersParams()})
+	check(err)
+	activeClusters, err := clusters.Collect(0)
+	check(err)
+	fmt.Printf("active clusters: %d\n", len(activeClusters))
+
+	events, err := api.QueryEvents(ctx, fakehome.ActiveClusterID, &client.EventQueryOptions{Limit: 25})
+	check(err)
+	allEvents, err := events.Collect(0)
+	check(err)
+	if len(allEvents) != fakehome.NumActiveEvents {
+		check(fmt.Errorf("expected %d events, got %d", fakehome.NumActiveEvents, len(allEvents)))
+	}
+	fmt.Printf("events: %d\n", len(allEvents))
+}
+
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %s\n", err)
+		os.Exit(1)
+	}
 }